@@ -0,0 +1,183 @@
+package tigertonic
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MediaRange is one element of an Accept header as described by RFC 7231
+// section 5.3.2.  Type and Subtype may be "*".  Params excludes the q
+// parameter and any accept-extensions that follow it.
+type MediaRange struct {
+	Type, Subtype string
+	Params        map[string]string
+	Q             float64
+}
+
+// ParseAccept parses an Accept header into its media ranges in the order
+// they appear.  Parameter values may be quoted-strings, which may contain
+// commas, semicolons, and backslash-escaped characters.  Malformed ranges
+// are skipped.
+func ParseAccept(accept string) []MediaRange {
+	var ranges []MediaRange
+	for _, s := range splitQuoted(accept, ',') {
+		parts := splitQuoted(s, ';')
+		mediaType := strings.ToLower(strings.TrimSpace(parts[0]))
+		if "*" == mediaType {
+			mediaType = "*/*"
+		}
+		slash := strings.Index(mediaType, "/")
+		if -1 == slash || 0 == slash || len(mediaType)-1 == slash {
+			continue
+		}
+		mr := MediaRange{
+			Type:    mediaType[:slash],
+			Subtype: mediaType[slash+1:],
+			Params:  make(map[string]string),
+			Q:       1,
+		}
+		if "*" == mr.Type && "*" != mr.Subtype {
+			continue
+		}
+		valid := true
+		for _, param := range parts[1:] {
+			eq := strings.Index(param, "=")
+			if -1 == eq {
+				continue
+			}
+			key := strings.ToLower(strings.TrimSpace(param[:eq]))
+			value := unquote(strings.TrimSpace(param[eq+1:]))
+			if "q" == key {
+				q, err := strconv.ParseFloat(value, 64)
+				if nil != err || q < 0 || q > 1 {
+					valid = false
+				}
+				mr.Q = q
+				break // Everything after q is an accept-extension.
+			}
+			mr.Params[key] = value
+		}
+		if valid {
+			ranges = append(ranges, mr)
+		}
+	}
+	return ranges
+}
+
+// splitQuoted splits s around each instance of sep that isn't inside a
+// quoted-string.
+func splitQuoted(s string, sep byte) []string {
+	var (
+		parts  []string
+		quoted bool
+		start  int
+	)
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quoted && '\\' == s[i]:
+			i++ // Skip the escaped character, whatever it is.
+		case '"' == s[i]:
+			quoted = !quoted
+		case !quoted && sep == s[i]:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unquote returns the contents of a quoted-string with its backslash
+// escapes resolved or s itself if it isn't quoted.
+func unquote(s string) string {
+	if len(s) < 2 || '"' != s[0] || '"' != s[len(s)-1] {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if '\\' == s[i] && i+1 < len(s) {
+			i++
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
+// match reports how specifically this media range matches the given media
+// type and parameters or -1 if it doesn't match at all.
+func (mr MediaRange) match(mediaType string, params map[string]string) int {
+	slash := strings.Index(mediaType, "/")
+	if -1 == slash {
+		return -1
+	}
+	specificity := 0
+	if "*" != mr.Type {
+		if mr.Type != mediaType[:slash] {
+			return -1
+		}
+		specificity++
+	}
+	if "*" != mr.Subtype {
+		if mr.Subtype != mediaType[slash+1:] {
+			return -1
+		}
+		specificity++
+	}
+	for key, value := range mr.Params {
+		if !strings.EqualFold(params[key], value) {
+			return -1
+		}
+		specificity++
+	}
+	return specificity
+}
+
+// quality returns the q-value the media ranges assign to the given media
+// type, taken from the most specific matching range, and that range's
+// specificity.
+func quality(ranges []MediaRange, offer string) (float64, int) {
+	mediaType, params, err := mime.ParseMediaType(offer)
+	if nil != err {
+		return 0, -1
+	}
+	q, best := 0.0, -1
+	for _, mr := range ranges {
+		if specificity := mr.match(mediaType, params); specificity > best {
+			q, best = mr.Q, specificity
+		}
+	}
+	return q, best
+}
+
+// NegotiateContentType returns the offered media type most preferred by the
+// request's Accept header, honoring q-values, specificity, and parameters.
+// Ties go to the more specific match and then to the earlier offer.  A
+// missing Accept header accepts the first offer.  It returns "" if the
+// Accept header allows none of the offers.
+func NegotiateContentType(r *http.Request, offers ...string) string {
+	accept := r.Header.Get("Accept")
+	if "" == strings.TrimSpace(accept) {
+		if 0 == len(offers) {
+			return ""
+		}
+		return offers[0]
+	}
+	ranges := ParseAccept(accept)
+	var (
+		best        string
+		bestQ       float64
+		bestMatched = -1
+	)
+	for _, offer := range offers {
+		q, specificity := quality(ranges, offer)
+		if 0 == q {
+			continue
+		}
+		if q > bestQ || q == bestQ && specificity > bestMatched {
+			best, bestQ, bestMatched = offer, q, specificity
+		}
+	}
+	return best
+}
@@ -0,0 +1,91 @@
+package tigertonic
+
+import (
+	"net/http"
+	"testing"
+)
+
+var ttTestNegotiateContentType = []struct {
+	accept string
+	offers []string
+	out    string
+}{
+	{"", []string{"application/json", "text/plain"}, "application/json"},
+	{"*/*", []string{"application/json", "text/plain"}, "application/json"},
+	{"application/json;q=0", []string{"application/json"}, ""},
+	{"application/jsonx", []string{"application/json"}, ""},
+	{"text/plain;q=0.5, application/json", []string{"text/plain", "application/json"}, "application/json"},
+	{"application/json;q=0.5, text/plain", []string{"application/json", "text/plain"}, "text/plain"},
+	{"*/*;q=0.1, application/xml", []string{"application/json", "application/xml"}, "application/xml"},
+	{"application/*, application/xml", []string{"application/json", "application/xml"}, "application/xml"},
+	{"text/*;q=0.1, text/plain;q=0", []string{"text/plain"}, ""},
+	{"text/*;q=0.1, text/plain;q=0", []string{"text/plain", "text/html"}, "text/html"},
+	{"text/plain;charset=utf-8", []string{"text/plain"}, ""},
+	{"text/plain;charset=utf-8", []string{"text/plain; charset=UTF-8"}, "text/plain; charset=UTF-8"},
+	{"text/plain;q=0.5;charset=utf-8", []string{"text/plain"}, "text/plain"},
+	{"APPLICATION/JSON", []string{"application/json"}, "application/json"},
+	{"application/json;q=2, text/plain", []string{"application/json", "text/plain"}, "text/plain"},
+	{"*", []string{"image/png"}, "image/png"},
+	{`text/plain;foo="a,b", application/json;q=0.5`, []string{"text/plain;foo=\"a,b\"", "application/json"}, "text/plain;foo=\"a,b\""},
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	for i, tt := range ttTestNegotiateContentType {
+		r := &http.Request{Header: http.Header{"Accept": []string{tt.accept}}}
+		if x := NegotiateContentType(r, tt.offers...); x != tt.out {
+			t.Errorf("Test %d expected %q, got %q", i, tt.out, x)
+		}
+	}
+}
+
+func TestParseAccept(t *testing.T) {
+	ranges := ParseAccept("text/html;level=1;q=0.7;ext=foo, bogus, */*;q=0.1")
+	if 2 != len(ranges) {
+		t.Fatal(ranges)
+	}
+	if "text" != ranges[0].Type || "html" != ranges[0].Subtype || 0.7 != ranges[0].Q {
+		t.Fatal(ranges[0])
+	}
+	if "1" != ranges[0].Params["level"] || "" != ranges[0].Params["ext"] {
+		t.Fatal(ranges[0].Params)
+	}
+	if "*" != ranges[1].Type || "*" != ranges[1].Subtype || 0.1 != ranges[1].Q {
+		t.Fatal(ranges[1])
+	}
+}
+
+func TestParseAcceptQuoted(t *testing.T) {
+	ranges := ParseAccept(`text/plain;foo="a,b;c";q=0.5, text/html;bar="say \"hi\""`)
+	if 2 != len(ranges) {
+		t.Fatal(ranges)
+	}
+	if "plain" != ranges[0].Subtype || "a,b;c" != ranges[0].Params["foo"] || 0.5 != ranges[0].Q {
+		t.Fatal(ranges[0])
+	}
+	if "html" != ranges[1].Subtype || `say "hi"` != ranges[1].Params["bar"] {
+		t.Fatal(ranges[1])
+	}
+}
+
+func TestNotFoundPrefersPlaintext(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/", nil)
+	r.Header.Set("Accept", "application/json;q=0.5, text/plain")
+	NotFoundHandler{}.ServeHTTP(w, r)
+	if http.StatusNotFound != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "text/plain" != w.Header().Get("Content-Type") {
+		t.Fatal(w.Header().Get("Content-Type"))
+	}
+}
+
+func TestNotFoundRefusesJSON(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/", nil)
+	r.Header.Set("Accept", "application/json;q=0")
+	NotFoundHandler{}.ServeHTTP(w, r)
+	if "text/plain" != w.Header().Get("Content-Type") {
+		t.Fatal(w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,247 @@
+package tigertonic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Principal is who made a request, as established by authentication
+// middleware, and what they're allowed to do.
+type Principal struct {
+	Name   string
+	Roles  []string
+	Scopes []string
+}
+
+// String returns the Principal's name.
+func (p *Principal) String() string {
+	return p.Name
+}
+
+// PrincipalFromContext returns the Principal added to the context.Context
+// by an AccessPolicy, as passed to Marshaled functions that take one, or nil
+// if there isn't one.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+type principalKey struct{}
+
+// Authorizer finds the Principal that made a request.  It returns a nil
+// Principal if the request isn't authenticated.
+type Authorizer interface {
+	Principal(*http.Request) (*Principal, error)
+}
+
+// AuthorizerFunc is a function that's an Authorizer.
+type AuthorizerFunc func(*http.Request) (*Principal, error)
+
+// Principal calls the function.
+func (f AuthorizerFunc) Principal(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+// JWTAuthorizer finds the Principal in the claims BearerAuth verified: its
+// name is the sub claim, its scopes are the space-separated scope claim or
+// the scp claim, and its roles are the roles claim.
+var JWTAuthorizer = AuthorizerFunc(func(r *http.Request) (*Principal, error) {
+	claims := JWTClaimsFromContext(r.Context())
+	if nil == claims {
+		return nil, nil
+	}
+	p := &Principal{Name: claims.Subject(), Roles: claims.Strings("roles")}
+	if scope := claims.String("scope"); "" != scope {
+		p.Scopes = strings.Fields(scope)
+	} else {
+		p.Scopes = claims.Strings("scp")
+	}
+	return p, nil
+})
+
+// HTTPBasicAuthAuthorizer returns an Authorizer that finds the Principal
+// by the username HTTPBasicAuth verified and gives it the roles in the
+// given map.  The AccessPolicy must be nested inside HTTPBasicAuth or
+// HTTPBasicAuthFunc; the Authorization header alone is never trusted.
+func HTTPBasicAuthAuthorizer(roles map[string][]string) Authorizer {
+	return AuthorizerFunc(func(r *http.Request) (*Principal, error) {
+		username := HTTPBasicAuthUsernameFromContext(r.Context())
+		if "" == username {
+			return nil, nil
+		}
+		return &Principal{Name: username, Roles: roles[username]}, nil
+	})
+}
+
+// AccessPolicy guards routes with the roles or scopes they require.  A
+// request whose Principal can't be found gets 401 Unauthorized and one whose
+// Principal lacks the required roles or scopes gets 403 Forbidden.  Every
+// denial is logged as an audit record.
+type AccessPolicy struct {
+
+	// Authorizer finds the Principal of each request.
+	Authorizer Authorizer
+
+	// Challenge, if not empty, is sent in the WWW-Authenticate header of
+	// 401 responses, as in `Bearer realm="Tiger Tonic"`.
+	Challenge string
+
+	// Logger logs an audit record of each denial.  It defaults to standard
+	// error.
+	Logger Logger
+}
+
+// NewAccessPolicy makes an AccessPolicy that finds Principals with the
+// given Authorizer.
+func NewAccessPolicy(authorizer Authorizer) *AccessPolicy {
+	return &AccessPolicy{
+		Authorizer: authorizer,
+		Logger:     log.New(os.Stderr, "", log.LstdFlags),
+	}
+}
+
+// HandleRoles registers the http.Handler for the given HTTP method and URL
+// pattern in the TrieServeMux, requiring any of the given roles.  The roles
+// appear in the Route returned for it by Routes.
+func (policy *AccessPolicy) HandleRoles(mux *TrieServeMux, method, pattern string, h http.Handler, roles ...string) {
+	mux.Handle(method, pattern, policy.RequireRoles(h, roles...))
+}
+
+// HandleScopes registers the http.Handler for the given HTTP method and URL
+// pattern in the TrieServeMux, requiring all of the given scopes.  The scopes
+// appear in the Route returned for it by Routes.
+func (policy *AccessPolicy) HandleScopes(mux *TrieServeMux, method, pattern string, h http.Handler, scopes ...string) {
+	mux.Handle(method, pattern, policy.RequireScopes(h, scopes...))
+}
+
+// RequireRoles returns an http.Handler that conditionally calls another
+// http.Handler if the request's Principal has any of the given roles.  The
+// Principal is added to the request's context.Context, where
+// PrincipalFromContext can find it.
+func (policy *AccessPolicy) RequireRoles(h http.Handler, roles ...string) *AccessHandler {
+	return &AccessHandler{Roles: roles, handler: policy.require(h, func(p *Principal) error {
+		if 0 == len(roles) || containsAny(p.Roles, roles) {
+			return nil
+		}
+		return fmt.Errorf("requires any role of %s", strings.Join(roles, ", "))
+	})}
+}
+
+// RequireScopes returns an http.Handler that conditionally calls another
+// http.Handler if the request's Principal has all of the given scopes.  The
+// Principal is added to the request's context.Context, where
+// PrincipalFromContext can find it.
+func (policy *AccessPolicy) RequireScopes(h http.Handler, scopes ...string) *AccessHandler {
+	return &AccessHandler{Scopes: scopes, handler: policy.require(h, func(p *Principal) error {
+		var missing []string
+		for _, scope := range scopes {
+			if !containsAny(p.Scopes, []string{scope}) {
+				missing = append(missing, scope)
+			}
+		}
+		if 0 == len(missing) {
+			return nil
+		}
+		return fmt.Errorf("requires scope %s", strings.Join(missing, ", "))
+	})}
+}
+
+// AccessHandler is an http.Handler that requires the roles or scopes an
+// AccessPolicy checks before calling another http.Handler.
+type AccessHandler struct {
+	Roles   []string
+	Scopes  []string
+	handler http.Handler
+}
+
+func (h *AccessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.handler.ServeHTTP(w, r)
+}
+
+// accessRequirements returns the roles and scopes required by the
+// AccessHandler the given http.Handler wraps, if there is one.
+func accessRequirements(h http.Handler) (roles, scopes []string) {
+	if access, ok := findHandler(h, func(h http.Handler) bool {
+		_, ok := h.(*AccessHandler)
+		return ok
+	}).(*AccessHandler); ok {
+		return access.Roles, access.Scopes
+	}
+	return nil, nil
+}
+
+func (policy *AccessPolicy) require(h http.Handler, f func(*Principal) error) FirstHandler {
+	return IfContext(func(r *http.Request) (context.Context, http.Header, error) {
+		p, err := policy.Authorizer.Principal(r)
+		if nil == err && nil == p {
+			err = errors.New("not authenticated")
+		}
+		if nil != err {
+			if _, ok := err.(HTTPEquivError); !ok {
+				err = Unauthorized{err}
+			}
+			policy.audit(r, p, err)
+			var header http.Header
+			if "" != policy.Challenge && http.StatusUnauthorized == errorStatusCode(err) {
+				header = http.Header{"WWW-Authenticate": []string{policy.Challenge}}
+			}
+			return nil, header, err
+		}
+		if err := f(p); nil != err {
+			err = Forbidden{err}
+			policy.audit(r, p, err)
+			return nil, nil, err
+		}
+		return context.WithValue(r.Context(), principalKey{}, p), nil, nil
+	}, h)
+}
+
+// audit logs a record of a denied request.
+func (policy *AccessPolicy) audit(r *http.Request, p *Principal, err error) {
+	record := accessAuditLog{
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Reason:     err.Error(),
+		RemoteAddr: r.RemoteAddr,
+		RequestID:  RequestIDFromContext(r.Context()),
+		Status:     errorStatusCode(err),
+		Type:       "audit",
+	}
+	if nil != p {
+		record.Principal = p.Name
+	}
+	buf, err := json.Marshal(record)
+	if nil != err {
+		policy.Logger.Println(err.Error())
+		return
+	}
+	policy.Logger.Println("@json:", string(buf))
+}
+
+type accessAuditLog struct {
+	Method     string    `json:"method"`
+	Path       string    `json:"url"`
+	Principal  string    `json:"principal,omitempty"`
+	Reason     string    `json:"reason"`
+	RemoteAddr string    `json:"remote_addr"`
+	RequestID  RequestID `json:"@request_id,omitempty"`
+	Status     int       `json:"status"`
+	Type       string    `json:"@type"`
+}
+
+func containsAny(have, want []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if h == w {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package tigertonic
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAccessPolicyScopes(t *testing.T) {
+	v := NewJWTVerifier()
+	v.AddHMACKey("", []byte("secret"))
+	policy, _ := testAccessPolicy(JWTAuthorizer)
+	var p *Principal
+	h := BearerAuth(v, "Tiger Tonic", policy.RequireScopes(Marshaled(func(ctx context.Context, u *url.URL, h http.Header, _ interface{}) (int, http.Header, interface{}, error) {
+		p = PrincipalFromContext(ctx)
+		return http.StatusNoContent, nil, nil, nil
+	}), "stuff:read", "stuff:write"))
+	r, _ := http.NewRequest("GET", "http://example.com/stuff", nil)
+	r.Header.Set("Authorization", "Bearer "+testJWT(t, "HS256", "", []byte("secret"), JWTClaims{
+		"scope": "stuff:read stuff:write",
+		"sub":   "rcrowley",
+	}))
+	w := &testResponseWriter{}
+	h.ServeHTTP(w, r)
+	if http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+	if nil == p || "rcrowley" != p.Name || 2 != len(p.Scopes) {
+		t.Fatal(p)
+	}
+}
+
+func TestAccessPolicyScopesForbidden(t *testing.T) {
+	v := NewJWTVerifier()
+	v.AddHMACKey("", []byte("secret"))
+	policy, b := testAccessPolicy(JWTAuthorizer)
+	h := BearerAuth(v, "Tiger Tonic", policy.RequireScopes(&fatalHandler{t}, "stuff:read", "stuff:write"))
+	r, _ := http.NewRequest("POST", "http://example.com/stuff", nil)
+	r.Header.Set("Accept", "text/plain")
+	r.Header.Set("Authorization", "Bearer "+testJWT(t, "HS256", "", []byte("secret"), JWTClaims{
+		"scp": []string{"stuff:read"},
+		"sub": "rcrowley",
+	}))
+	w := &testResponseWriter{}
+	h.ServeHTTP(w, r)
+	if http.StatusForbidden != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "tigertonic.Forbidden: requires scope stuff:write" != w.Body.String() {
+		t.Fatal(w.Body.String())
+	}
+	if s := b.String(); `@json: {"method":"POST","url":"/stuff","principal":"rcrowley","reason":"requires scope stuff:write","remote_addr":"","status":403,"@type":"audit"}`+"\n" != s {
+		t.Fatal(s)
+	}
+}
+
+func TestAccessPolicyRoles(t *testing.T) {
+	policy, _ := testAccessPolicy(HTTPBasicAuthAuthorizer(map[string][]string{
+		"admin": {"admin", "user"},
+		"user":  {"user"},
+	}))
+	h := HTTPBasicAuth(
+		map[string]string{"admin": "password", "user": "password"},
+		"Tiger Tonic",
+		policy.RequireRoles(NotFoundHandler{}, "admin", "operator"),
+	)
+	r, _ := http.NewRequest("GET", "http://example.com/", nil)
+	r.SetBasicAuth("admin", "password")
+	w := &testResponseWriter{}
+	h.ServeHTTP(w, r)
+	if http.StatusNotFound != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	r.SetBasicAuth("user", "password")
+	w = &testResponseWriter{}
+	h.ServeHTTP(w, r)
+	if http.StatusForbidden != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+}
+
+func TestAccessPolicyRolesUnverified(t *testing.T) {
+	policy, _ := testAccessPolicy(HTTPBasicAuthAuthorizer(map[string][]string{
+		"admin": {"admin"},
+	}))
+	r, _ := http.NewRequest("GET", "http://example.com/", nil)
+	r.SetBasicAuth("admin", "wrong-password")
+	w := &testResponseWriter{}
+	policy.RequireRoles(&fatalHandler{t}, "admin").ServeHTTP(w, r)
+	if http.StatusUnauthorized != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+}
+
+func TestAccessPolicyUnauthenticated(t *testing.T) {
+	policy, b := testAccessPolicy(JWTAuthorizer)
+	policy.Challenge = `Bearer realm="Tiger Tonic"`
+	r, _ := http.NewRequest("GET", "http://example.com/", nil)
+	r.RemoteAddr = "127.0.0.1:48879"
+	w := &testResponseWriter{}
+	policy.RequireRoles(&fatalHandler{t}).ServeHTTP(w, r)
+	if http.StatusUnauthorized != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if `Bearer realm="Tiger Tonic"` != w.Header().Get("WWW-Authenticate") {
+		t.Fatal(w.Header())
+	}
+	if s := b.String(); !strings.Contains(s, `"reason":"not authenticated","remote_addr":"127.0.0.1:48879","status":401`) {
+		t.Fatal(s)
+	}
+}
+
+func TestAccessPolicyHandle(t *testing.T) {
+	policy, _ := testAccessPolicy(JWTAuthorizer)
+	mux := NewTrieServeMux()
+	policy.HandleScopes(mux, "POST", "/stuff", NotFoundHandler{}, "stuff:write")
+	policy.HandleRoles(mux, "DELETE", "/stuff/{id}", Logged(NotFoundHandler{}, nil), "admin")
+	mux.Handle("GET", "/stuff", NotFoundHandler{})
+	routes := mux.Routes()
+	if 3 != len(routes) {
+		t.Fatal(routes)
+	}
+	if "GET" != routes[0].Method || nil != routes[0].Roles || nil != routes[0].Scopes {
+		t.Fatal(routes[0])
+	}
+	if "POST" != routes[1].Method || 1 != len(routes[1].Scopes) || "stuff:write" != routes[1].Scopes[0] {
+		t.Fatal(routes[1])
+	}
+	if "DELETE" != routes[2].Method || 1 != len(routes[2].Roles) || "admin" != routes[2].Roles[0] {
+		t.Fatal(routes[2])
+	}
+	v := NewJWTVerifier()
+	v.AddHMACKey("", []byte("secret"))
+	r, _ := http.NewRequest("POST", "http://example.com/stuff", nil)
+	r.Header.Set("Authorization", "Bearer "+testJWT(t, "HS256", "", []byte("secret"), JWTClaims{
+		"scope": "stuff:read",
+		"sub":   "rcrowley",
+	}))
+	w := &testResponseWriter{}
+	BearerAuth(v, "Tiger Tonic", mux).ServeHTTP(w, r)
+	if http.StatusForbidden != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+}
+
+func testAccessPolicy(authorizer Authorizer) (*AccessPolicy, *bytes.Buffer) {
+	policy := NewAccessPolicy(authorizer)
+	b := &bytes.Buffer{}
+	policy.Logger = log.New(b, "", 0)
+	return policy, b
+}
@@ -0,0 +1,367 @@
+package tigertonic
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// BearerAuth returns an http.Handler that conditionally calls another
+// http.Handler if the request includes an Authorization header with a bearer
+// token the JWTVerifier accepts.  The token's claims are added to the
+// request's context.Context, where JWTClaimsFromContext can find them.
+// Otherwise, respond 401 Unauthorized.
+func BearerAuth(verifier *JWTVerifier, realm string, h http.Handler) FirstHandler {
+	return BearerAuthFunc(verifier.Verify, realm, h)
+}
+
+// BearerAuthFunc returns an http.Handler that conditionally calls another
+// http.Handler if the request includes an Authorization header with a bearer
+// token that produces a nil error when passed to the given function.  The
+// claims it returns are added to the request's context.Context, where
+// JWTClaimsFromContext can find them.  Otherwise, respond 401 Unauthorized.
+func BearerAuthFunc(
+	f func(string) (JWTClaims, error),
+	realm string,
+	h http.Handler,
+) FirstHandler {
+	return IfContext(func(r *http.Request) (context.Context, http.Header, error) {
+		token, err := bearerAuth(r.Header)
+		if nil != err {
+			return nil, http.Header{
+				"WWW-Authenticate": []string{fmt.Sprintf("Bearer realm=\"%s\"", realm)},
+			}, err
+		}
+		claims, err := f(token)
+		if nil != err {
+			return nil, http.Header{
+				"WWW-Authenticate": []string{fmt.Sprintf(
+					"Bearer realm=\"%s\", error=\"invalid_token\", error_description=\"%s\"",
+					realm,
+					strings.Replace(err.Error(), "\"", "'", -1),
+				)},
+			}, Unauthorized{err}
+		}
+		return context.WithValue(r.Context(), jwtClaimsKey{}, claims), nil, nil
+	}, h)
+}
+
+func bearerAuth(h http.Header) (string, error) {
+	authorization := h.Get("Authorization")
+	if 7 > len(authorization) || !strings.EqualFold("Bearer ", authorization[:7]) {
+		return "", Unauthorized{errors.New("no bearer token specified")}
+	}
+	return strings.TrimSpace(authorization[7:]), nil
+}
+
+// JWTClaims are the claims in a verified JSON Web Token.
+type JWTClaims map[string]interface{}
+
+// JWTClaimsFromContext returns the claims added to the context.Context by
+// BearerAuth, as passed to Marshaled functions that take one, or nil if
+// there aren't any.
+func JWTClaimsFromContext(ctx context.Context) JWTClaims {
+	claims, _ := ctx.Value(jwtClaimsKey{}).(JWTClaims)
+	return claims
+}
+
+// String returns the named claim if it's a string and the empty string
+// otherwise.
+func (claims JWTClaims) String(name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+// Strings returns the named claim as a slice whether it's a single string
+// or an array of them.
+func (claims JWTClaims) Strings(name string) []string {
+	switch v := claims[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		ss := make([]string, 0, len(v))
+		for _, i := range v {
+			if s, ok := i.(string); ok {
+				ss = append(ss, s)
+			}
+		}
+		return ss
+	}
+	return nil
+}
+
+// Subject returns the sub claim.
+func (claims JWTClaims) Subject() string {
+	return claims.String("sub")
+}
+
+// time returns the named NumericDate claim and whether it's present.  A
+// claim that's present but isn't a number is an error rather than absent so
+// a token can't dodge its exp or nbf check.
+func (claims JWTClaims) time(name string) (time.Time, bool, error) {
+	v, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	var f float64
+	switch v := v.(type) {
+	case float64:
+		f = v
+	case json.Number:
+		var err error
+		if f, err = v.Float64(); nil != err {
+			return time.Time{}, true, fmt.Errorf("malformed %s claim", name)
+		}
+	default:
+		return time.Time{}, true, fmt.Errorf("malformed %s claim", name)
+	}
+	return time.Unix(0, int64(f*float64(time.Second))), true, nil
+}
+
+type jwtClaimsKey struct{}
+
+// JWTVerifier verifies JSON Web Tokens signed with HS256, RS256, or ES256
+// by any of its keys and checks their exp, nbf, aud, and iss claims.
+type JWTVerifier struct {
+
+	// Audience, if not empty, must appear in each token's aud claim.
+	Audience string
+
+	// Issuer, if not empty, must be each token's iss claim.
+	Issuer string
+
+	// Leeway allows for clock skew when checking exp and nbf.
+	Leeway time.Duration
+
+	keys map[string]jwtKey
+}
+
+type jwtKey struct {
+	alg string
+	key interface{}
+}
+
+// NewJWTVerifier makes a new JWTVerifier without any keys.
+func NewJWTVerifier() *JWTVerifier {
+	return &JWTVerifier{keys: make(map[string]jwtKey)}
+}
+
+// AddHMACKey adds a secret that verifies HS256 tokens with the given key ID,
+// which may be empty for tokens without one.
+func (v *JWTVerifier) AddHMACKey(kid string, secret []byte) {
+	v.keys[kid] = jwtKey{"HS256", secret}
+}
+
+// AddPublicKey adds an *rsa.PublicKey that verifies RS256 tokens or a P-256
+// *ecdsa.PublicKey that verifies ES256 tokens with the given key ID, which
+// may be empty for tokens without one.
+func (v *JWTVerifier) AddPublicKey(kid string, key crypto.PublicKey) error {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		v.keys[kid] = jwtKey{"RS256", key}
+	case *ecdsa.PublicKey:
+		if elliptic.P256() != key.Curve {
+			return fmt.Errorf("%s isn't supported", key.Curve.Params().Name)
+		}
+		v.keys[kid] = jwtKey{"ES256", key}
+	default:
+		return fmt.Errorf("%T isn't supported", key)
+	}
+	return nil
+}
+
+// LoadJWKS adds every signing key in the JSON Web Key Set file with the
+// given pathname.
+func (v *JWTVerifier) LoadJWKS(pathname string) error {
+	buf, err := ioutil.ReadFile(pathname)
+	if nil != err {
+		return err
+	}
+	var jwks struct {
+		Keys []struct {
+			Crv, E, K, Kid, Kty, N, Use, X, Y string
+		}
+	}
+	if err := json.Unmarshal(buf, &jwks); nil != err {
+		return fmt.Errorf("%s: %s", pathname, err)
+	}
+	for _, jwk := range jwks.Keys {
+		if "" != jwk.Use && "sig" != jwk.Use {
+			continue
+		}
+		var err error
+		switch jwk.Kty {
+		case "oct":
+			var secret []byte
+			if secret, err = jwtDecode(jwk.K); nil == err {
+				v.AddHMACKey(jwk.Kid, secret)
+			}
+		case "RSA":
+			var n, e *big.Int
+			if n, err = jwtDecodeInt(jwk.N); nil == err {
+				if e, err = jwtDecodeInt(jwk.E); nil == err {
+					err = v.AddPublicKey(jwk.Kid, &rsa.PublicKey{N: n, E: int(e.Int64())})
+				}
+			}
+		case "EC":
+			if "P-256" != jwk.Crv {
+				err = fmt.Errorf("curve %s isn't supported", jwk.Crv)
+				break
+			}
+			var x, y *big.Int
+			if x, err = jwtDecodeInt(jwk.X); nil == err {
+				if y, err = jwtDecodeInt(jwk.Y); nil == err {
+					err = v.AddPublicKey(jwk.Kid, &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y})
+				}
+			}
+		default:
+			err = fmt.Errorf("key type %s isn't supported", jwk.Kty)
+		}
+		if nil != err {
+			return fmt.Errorf("%s: key %s: %s", pathname, jwk.Kid, err)
+		}
+	}
+	return nil
+}
+
+// Verify checks the token's signature and claims and returns the claims if
+// they're valid.
+func (v *JWTVerifier) Verify(token string) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if 3 != len(parts) {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg, Kid string
+	}
+	if err := jwtUnmarshal(parts[0], &header); nil != err {
+		return nil, err
+	}
+	key, ok := v.keys[header.Kid]
+	if !ok && "" == header.Kid {
+		key, ok = v.onlyKey()
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key %s", header.Kid)
+	}
+	if key.alg != header.Alg {
+		return nil, fmt.Errorf("algorithm %s doesn't match key", header.Alg)
+	}
+	signature, err := jwtDecode(parts[2])
+	if nil != err {
+		return nil, err
+	}
+	if err := key.verify([]byte(parts[0]+"."+parts[1]), signature); nil != err {
+		return nil, err
+	}
+	var claims JWTClaims
+	if err := jwtUnmarshal(parts[1], &claims); nil != err {
+		return nil, err
+	}
+	if err := v.check(claims, time.Now()); nil != err {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// onlyKey returns the JWTVerifier's key if it has exactly one, which tokens
+// without a key ID may use.
+func (v *JWTVerifier) onlyKey() (jwtKey, bool) {
+	if 1 == len(v.keys) {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	return jwtKey{}, false
+}
+
+func (v *JWTVerifier) check(claims JWTClaims, now time.Time) error {
+	exp, ok, err := claims.time("exp")
+	if nil != err {
+		return err
+	}
+	if ok && !now.Before(exp.Add(v.Leeway)) {
+		return errors.New("token expired")
+	}
+	nbf, ok, err := claims.time("nbf")
+	if nil != err {
+		return err
+	}
+	if ok && now.Add(v.Leeway).Before(nbf) {
+		return errors.New("token not valid yet")
+	}
+	if "" != v.Issuer && v.Issuer != claims.String("iss") {
+		return errors.New("wrong issuer")
+	}
+	if "" != v.Audience {
+		for _, aud := range claims.Strings("aud") {
+			if v.Audience == aud {
+				return nil
+			}
+		}
+		return errors.New("wrong audience")
+	}
+	return nil
+}
+
+func (key jwtKey) verify(signed, signature []byte) error {
+	sum := sha256.Sum256(signed)
+	switch k := key.key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return errJWTSignature
+		}
+	case *rsa.PublicKey:
+		if nil != rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], signature) {
+			return errJWTSignature
+		}
+	case *ecdsa.PublicKey:
+		if 64 != len(signature) {
+			return errJWTSignature
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(k, sum[:], r, s) {
+			return errJWTSignature
+		}
+	}
+	return nil
+}
+
+var errJWTSignature = errors.New("invalid signature")
+
+func jwtDecode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+func jwtDecodeInt(s string) (*big.Int, error) {
+	buf, err := jwtDecode(s)
+	if nil != err {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(buf), nil
+}
+
+func jwtUnmarshal(s string, v interface{}) error {
+	buf, err := jwtDecode(s)
+	if nil != err {
+		return err
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(buf)))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
@@ -0,0 +1,201 @@
+package tigertonic
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBearerAuthHS256(t *testing.T) {
+	v := NewJWTVerifier()
+	v.AddHMACKey("", []byte("secret"))
+	token := testJWT(t, "HS256", "", []byte("secret"), JWTClaims{"sub": "rcrowley"})
+	var claims JWTClaims
+	w := testBearerAuth(v, token, func(ctx context.Context) {
+		claims = JWTClaimsFromContext(ctx)
+	})
+	if http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+	if "rcrowley" != claims.Subject() {
+		t.Fatal(claims)
+	}
+}
+
+func TestBearerAuthRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if nil != err {
+		t.Fatal(err)
+	}
+	v := NewJWTVerifier()
+	if err := v.AddPublicKey("rsa", &key.PublicKey); nil != err {
+		t.Fatal(err)
+	}
+	token := testJWT(t, "RS256", "rsa", key, JWTClaims{"sub": "rcrowley"})
+	if w := testBearerAuth(v, token, nil); http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+}
+
+func TestBearerAuthES256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	v := NewJWTVerifier()
+	if err := v.AddPublicKey("ec", &key.PublicKey); nil != err {
+		t.Fatal(err)
+	}
+	token := testJWT(t, "ES256", "ec", key, JWTClaims{"sub": "rcrowley"})
+	if w := testBearerAuth(v, token, nil); http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+}
+
+func TestBearerAuthJWKS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	dirname, err := ioutil.TempDir("", "tigertonic")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	pathname := filepath.Join(dirname, "jwks.json")
+	enc := base64.RawURLEncoding.EncodeToString
+	if err := ioutil.WriteFile(pathname, []byte(fmt.Sprintf(
+		`{"keys":[{"kty":"EC","kid":"ec","crv":"P-256","x":"%s","y":"%s"},{"kty":"oct","kid":"hmac","k":"%s"},{"kty":"RSA","kid":"enc","use":"enc"}]}`,
+		enc(key.X.Bytes()),
+		enc(key.Y.Bytes()),
+		enc([]byte("secret")),
+	)), 0666); nil != err {
+		t.Fatal(err)
+	}
+	v := NewJWTVerifier()
+	if err := v.LoadJWKS(pathname); nil != err {
+		t.Fatal(err)
+	}
+	token := testJWT(t, "ES256", "ec", key, JWTClaims{"sub": "rcrowley"})
+	if w := testBearerAuth(v, token, nil); http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+	token = testJWT(t, "HS256", "hmac", []byte("secret"), JWTClaims{"sub": "rcrowley"})
+	if w := testBearerAuth(v, token, nil); http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+}
+
+func TestBearerAuthNoToken(t *testing.T) {
+	v := NewJWTVerifier()
+	v.AddHMACKey("", []byte("secret"))
+	w := testBearerAuth(v, "", nil)
+	if http.StatusUnauthorized != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if `Bearer realm="Tiger Tonic"` != w.Header().Get("WWW-Authenticate") {
+		t.Fatal(w.Header())
+	}
+}
+
+func TestBearerAuthInvalid(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	v := NewJWTVerifier()
+	v.Audience, v.Issuer = "tigertonic", "https://example.com/"
+	v.AddHMACKey("hmac", []byte("secret"))
+	v.AddPublicKey("ec", &key.PublicKey)
+	valid := JWTClaims{"aud": []string{"other", "tigertonic"}, "iss": "https://example.com/"}
+	if w := testBearerAuth(v, testJWT(t, "HS256", "hmac", []byte("secret"), valid), nil); http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+	now := time.Now().Unix()
+	for description, token := range map[string]string{
+		"invalid signature":             testJWT(t, "HS256", "hmac", []byte("wrong"), valid),
+		"algorithm HS256 doesn't match": testJWT(t, "HS256", "ec", []byte("secret"), valid),
+		"unknown key":                   testJWT(t, "HS256", "", []byte("secret"), valid),
+		"token expired":                 testJWT(t, "HS256", "hmac", []byte("secret"), JWTClaims{"aud": "tigertonic", "iss": "https://example.com/", "exp": now - 1}),
+		"token not valid yet":           testJWT(t, "HS256", "hmac", []byte("secret"), JWTClaims{"aud": "tigertonic", "iss": "https://example.com/", "nbf": now + 60}),
+		"malformed exp claim":           testJWT(t, "HS256", "hmac", []byte("secret"), JWTClaims{"aud": "tigertonic", "iss": "https://example.com/", "exp": "never"}),
+		"malformed nbf claim":           testJWT(t, "HS256", "hmac", []byte("secret"), JWTClaims{"aud": "tigertonic", "iss": "https://example.com/", "nbf": nil}),
+		"wrong audience":                testJWT(t, "HS256", "hmac", []byte("secret"), JWTClaims{"aud": "other", "iss": "https://example.com/"}),
+		"wrong issuer":                  testJWT(t, "HS256", "hmac", []byte("secret"), JWTClaims{"aud": "tigertonic", "iss": "https://example.org/"}),
+		"malformed token":               "foo.bar",
+	} {
+		w := testBearerAuth(v, token, nil)
+		if http.StatusUnauthorized != w.StatusCode {
+			t.Fatal(description, w.StatusCode)
+		}
+		if challenge := w.Header().Get("WWW-Authenticate"); !strings.HasPrefix(challenge, `Bearer realm="Tiger Tonic", error="invalid_token", error_description="`+description) {
+			t.Fatal(description, challenge)
+		}
+	}
+}
+
+func testBearerAuth(v *JWTVerifier, token string, f func(context.Context)) *testResponseWriter {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/", nil)
+	if "" != token {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	BearerAuth(v, "Tiger Tonic", Marshaled(func(ctx context.Context, u *url.URL, h http.Header, _ interface{}) (int, http.Header, interface{}, error) {
+		if nil != f {
+			f(ctx)
+		}
+		return http.StatusNoContent, nil, nil, nil
+	})).ServeHTTP(w, r)
+	return w
+}
+
+// testJWT signs a JSON Web Token since the package only verifies them.
+func testJWT(t *testing.T, alg, kid string, key interface{}, claims JWTClaims) string {
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if "" != kid {
+		header["kid"] = kid
+	}
+	enc := base64.RawURLEncoding.EncodeToString
+	headerJSON, _ := json.Marshal(header)
+	claimsJSON, _ := json.Marshal(claims)
+	signed := enc(headerJSON) + "." + enc(claimsJSON)
+	sum := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:]); nil != err {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, sum[:])
+		if nil != err {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		rb, sb := r.Bytes(), s.Bytes()
+		copy(signature[32-len(rb):32], rb)
+		copy(signature[64-len(sb):], sb)
+	}
+	return signed + "." + enc(signature)
+}
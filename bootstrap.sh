@@ -1,3 +1,5 @@
 set -e -x
 
 go get "github.com/rcrowley/go-metrics"
+go get "golang.org/x/crypto/argon2"
+go get "golang.org/x/crypto/bcrypt"
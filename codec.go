@@ -0,0 +1,87 @@
+package tigertonic
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// Codec decodes request bodies from and encodes response bodies to a single
+// media type on behalf of Marshaler.
+type Codec interface {
+	Decode(r io.Reader, i interface{}) error
+	Encode(w io.Writer, i interface{}) error
+}
+
+// Codecs maps media types to the Codecs Marshaler uses to decode requests
+// by their Content-Type and encode responses by their Accept header.
+var Codecs = make(map[string]Codec)
+
+// codecTypes lists the media types in Codecs in the order they were
+// registered, which is the order of preference when the client doesn't
+// have one.
+var codecTypes []string
+
+// RegisterCodec adds or replaces the Codec for the given media type.
+func RegisterCodec(mediaType string, c Codec) {
+	if _, ok := Codecs[mediaType]; !ok {
+		codecTypes = append(codecTypes, mediaType)
+	}
+	Codecs[mediaType] = c
+}
+
+func init() {
+	RegisterCodec("application/json", jsonCodec{})
+	RegisterCodec("application/xml", xmlCodec{})
+	RegisterCodec("text/xml", xmlCodec{})
+	RegisterCodec("application/x-www-form-urlencoded", formCodec{})
+	RegisterCodec("application/msgpack", msgpackCodec{})
+	RegisterCodec("application/x-msgpack", msgpackCodec{})
+}
+
+// requestCodec returns the Codec registered for the request's Content-Type.
+func requestCodec(r *http.Request) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if nil != err {
+		return nil, false
+	}
+	c, ok := Codecs[mediaType]
+	return c, ok
+}
+
+// responseCodec returns the media type and Codec the request's Accept header
+// prefers for the response.  Browsers send Accept headers that rank XML
+// above a wildcard without really preferring it so the first media type
+// registered, JSON, is chosen whenever a wildcard allows it.
+func responseCodec(r *http.Request) (string, Codec, bool) {
+	mediaType := codecTypes[0]
+	if q, specificity := quality(ParseAccept(r.Header.Get("Accept")), mediaType); 0 == q || 2 <= specificity {
+		mediaType = NegotiateContentType(r, codecTypes...)
+	}
+	if "" == mediaType {
+		return "", nil, false
+	}
+	return mediaType, Codecs[mediaType], true
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Decode(r io.Reader, i interface{}) error {
+	return json.NewDecoder(r).Decode(i)
+}
+
+func (jsonCodec) Encode(w io.Writer, i interface{}) error {
+	return json.NewEncoder(w).Encode(i)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) Decode(r io.Reader, i interface{}) error {
+	return xml.NewDecoder(r).Decode(i)
+}
+
+func (xmlCodec) Encode(w io.Writer, i interface{}) error {
+	return xml.NewEncoder(w).Encode(i)
+}
@@ -0,0 +1,274 @@
+package tigertonic
+
+import (
+	"encoding"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formCodec decodes and encodes application/x-www-form-urlencoded bodies.
+// Struct fields are named as they would be by encoding/json and may be
+// strings, booleans, numbers, encoding.TextUnmarshalers, or slices or
+// pointers of those.
+type formCodec struct{}
+
+func (formCodec) Decode(r io.Reader, i interface{}) error {
+	buf, err := ioutil.ReadAll(r)
+	if nil != err {
+		return err
+	}
+	values, err := url.ParseQuery(string(buf))
+	if nil != err {
+		return err
+	}
+	v := reflect.ValueOf(i)
+	if reflect.Ptr != v.Kind() || v.IsNil() {
+		return fmt.Errorf("form: cannot decode into %T", i)
+	}
+	return decodeForm(values, v.Elem())
+}
+
+func (formCodec) Encode(w io.Writer, i interface{}) error {
+	values := make(url.Values)
+	if err := encodeForm(values, reflect.ValueOf(i)); nil != err {
+		return err
+	}
+	_, err := io.WriteString(w, values.Encode())
+	return err
+}
+
+func decodeForm(values url.Values, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Map:
+		if reflect.String != v.Type().Key().Kind() {
+			return fmt.Errorf("form: cannot decode into %s", v.Type())
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for key, ss := range values {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if reflect.Interface == elem.Kind() && 0 == elem.NumMethod() {
+				elem.Set(reflect.ValueOf(ss[0]))
+			} else if err := setFromStrings(elem, ss); nil != err {
+				return fmt.Errorf("form: %s: %s", key, err)
+			}
+			v.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		return nil
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if "" != f.PkgPath {
+				continue
+			}
+			if f.Anonymous && "" == f.Tag.Get("json") && reflect.Struct == f.Type.Kind() {
+				if err := decodeForm(values, v.Field(i)); nil != err {
+					return err
+				}
+				continue
+			}
+			name := jsonFieldName(f)
+			ss, ok := values[name]
+			if "-" == name || !ok {
+				continue
+			}
+			if err := setFromStrings(v.Field(i), ss); nil != err {
+				return fmt.Errorf("form: %s: %s", name, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("form: cannot decode into %s", v.Type())
+}
+
+func encodeForm(values url.Values, v reflect.Value) error {
+	for reflect.Ptr == v.Kind() || reflect.Interface == v.Kind() {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if reflect.String != v.Type().Key().Kind() {
+			return fmt.Errorf("form: cannot encode %s", v.Type())
+		}
+		for _, key := range v.MapKeys() {
+			ss, err := formatStrings(v.MapIndex(key))
+			if nil != err {
+				return err
+			}
+			values[key.String()] = append(values[key.String()], ss...)
+		}
+		return nil
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if "" != f.PkgPath {
+				continue
+			}
+			if f.Anonymous && "" == f.Tag.Get("json") && reflect.Struct == f.Type.Kind() {
+				if err := encodeForm(values, v.Field(i)); nil != err {
+					return err
+				}
+				continue
+			}
+			name := jsonFieldName(f)
+			if "-" == name {
+				continue
+			}
+			if strings.Contains(f.Tag.Get("json"), ",omitempty") && v.Field(i).IsZero() {
+				continue
+			}
+			ss, err := formatStrings(v.Field(i))
+			if nil != err {
+				return fmt.Errorf("form: %s: %s", name, err)
+			}
+			values[name] = append(values[name], ss...)
+		}
+		return nil
+	}
+	return fmt.Errorf("form: cannot encode %s", v.Type())
+}
+
+// setFromStrings converts strings from a form, URL, or query string into
+// the given settable value, allocating pointers and slices as needed.
+// time.Durations are parsed by time.ParseDuration and anything that
+// implements encoding.TextUnmarshaler, time.Time included, parses itself.
+func setFromStrings(v reflect.Value, ss []string) error {
+	if 0 == len(ss) {
+		return nil
+	}
+	if v.CanAddr() {
+		if tu, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return tu.UnmarshalText([]byte(ss[0]))
+		}
+	}
+	if durationType == v.Type() {
+		d, err := time.ParseDuration(ss[0])
+		if nil != err {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := setFromStrings(elem.Elem(), ss); nil != err {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Slice:
+		if reflect.Uint8 == v.Type().Elem().Kind() {
+			v.SetBytes([]byte(ss[0]))
+			return nil
+		}
+		slice := reflect.MakeSlice(v.Type(), len(ss), len(ss))
+		for i, s := range ss {
+			if err := setFromStrings(slice.Index(i), []string{s}); nil != err {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.String:
+		v.SetString(ss[0])
+	case reflect.Bool:
+		b, err := strconv.ParseBool(ss[0])
+		if nil != err {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(ss[0], 10, v.Type().Bits())
+		if nil != err {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(ss[0], 10, v.Type().Bits())
+		if nil != err {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(ss[0], v.Type().Bits())
+		if nil != err {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("cannot convert into %s", v.Type())
+	}
+	return nil
+}
+
+// canSetFromStrings reports whether setFromStrings can convert into values
+// of the given type.
+func canSetFromStrings(t reflect.Type) bool {
+	if t.Implements(textUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice:
+		return reflect.Uint8 == t.Elem().Kind() && reflect.Slice == t.Kind() || canSetFromStrings(t.Elem())
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// formatStrings is the inverse of setFromStrings.
+func formatStrings(v reflect.Value) ([]string, error) {
+	for reflect.Ptr == v.Kind() || reflect.Interface == v.Kind() {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if tm, ok := v.Interface().(encoding.TextMarshaler); ok {
+		buf, err := tm.MarshalText()
+		if nil != err {
+			return nil, err
+		}
+		return []string{string(buf)}, nil
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if reflect.Uint8 == v.Type().Elem().Kind() && reflect.Slice == v.Kind() {
+			return []string{string(v.Bytes())}, nil
+		}
+		ss := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s, err := formatStrings(v.Index(i))
+			if nil != err {
+				return nil, err
+			}
+			ss = append(ss, s...)
+		}
+		return ss, nil
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return []string{fmt.Sprint(v.Interface())}, nil
+	}
+	return nil, fmt.Errorf("cannot convert %s", v.Type())
+}
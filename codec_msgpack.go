@@ -0,0 +1,341 @@
+package tigertonic
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// msgpackCodec decodes and encodes MessagePack bodies.  Values pass through
+// their JSON representation on the way so that json struct tags, custom
+// json.Marshalers, and the like all behave exactly as they do for JSON.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Decode(r io.Reader, i interface{}) error {
+	buf, err := ioutil.ReadAll(r)
+	if nil != err {
+		return err
+	}
+	d := &msgpackDecoder{buf: buf}
+	v, err := d.decode()
+	if nil != err {
+		return err
+	}
+	if buf, err = json.Marshal(v); nil != err {
+		return err
+	}
+	return json.Unmarshal(buf, i)
+}
+
+func (msgpackCodec) Encode(w io.Writer, i interface{}) error {
+	buf, err := json.Marshal(i)
+	if nil != err {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); nil != err {
+		return err
+	}
+	e := &msgpackEncoder{}
+	if err := e.encode(v); nil != err {
+		return err
+	}
+	_, err = w.Write(e.buf.Bytes())
+	return err
+}
+
+type msgpackEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *msgpackEncoder) encode(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			e.buf.WriteByte(0xc3)
+		} else {
+			e.buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if i, err := v.Int64(); nil == err {
+			e.encodeInt(i)
+		} else if u, err := strconv.ParseUint(string(v), 10, 64); nil == err {
+			e.buf.WriteByte(0xcf)
+			e.write(u)
+		} else if f, err := v.Float64(); nil == err {
+			e.buf.WriteByte(0xcb)
+			e.write(math.Float64bits(f))
+		} else {
+			return err
+		}
+	case string:
+		e.encodeLength(len(v), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		e.buf.WriteString(v)
+	case []interface{}:
+		e.encodeLength(len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, elem := range v {
+			if err := e.encode(elem); nil != err {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		e.encodeLength(len(v), 0x80, 15, 0, 0xde, 0xdf)
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			e.encode(key)
+			if err := e.encode(v[key]); nil != err {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: cannot encode %T", v)
+	}
+	return nil
+}
+
+func (e *msgpackEncoder) encodeInt(i int64) {
+	switch {
+	case 0 <= i && i <= 127:
+		e.buf.WriteByte(byte(i))
+	case -32 <= i && i < 0:
+		e.buf.WriteByte(byte(int8(i)))
+	case math.MinInt8 <= i && i <= math.MaxInt8:
+		e.buf.WriteByte(0xd0)
+		e.write(int8(i))
+	case math.MinInt16 <= i && i <= math.MaxInt16:
+		e.buf.WriteByte(0xd1)
+		e.write(int16(i))
+	case math.MinInt32 <= i && i <= math.MaxInt32:
+		e.buf.WriteByte(0xd2)
+		e.write(int32(i))
+	default:
+		e.buf.WriteByte(0xd3)
+		e.write(i)
+	}
+}
+
+// encodeLength writes the header for a string, array, or map of the given
+// length.  A zero format byte means the format family has no 8-bit form.
+func (e *msgpackEncoder) encodeLength(n int, fix byte, fixMax int, f8, f16, f32 byte) {
+	switch {
+	case n <= fixMax:
+		e.buf.WriteByte(fix | byte(n))
+	case 0 != f8 && n <= math.MaxUint8:
+		e.buf.WriteByte(f8)
+		e.buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		e.buf.WriteByte(f16)
+		e.write(uint16(n))
+	default:
+		e.buf.WriteByte(f32)
+		e.write(uint32(n))
+	}
+}
+
+func (e *msgpackEncoder) write(v interface{}) {
+	binary.Write(&e.buf, binary.BigEndian, v)
+}
+
+type msgpackDecoder struct {
+	buf   []byte
+	depth int
+	i     int
+}
+
+// msgpackMaxDepth is how deeply arrays and maps may nest, the same limit
+// encoding/json places on JSON bodies.
+const msgpackMaxDepth = 10000
+
+var (
+	errMsgpackDepth = errors.New("msgpack: exceeded max depth")
+	errMsgpackShort = errors.New("msgpack: unexpected end of input")
+)
+
+func (d *msgpackDecoder) decode() (interface{}, error) {
+	b, err := d.next(1)
+	if nil != err {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case 0x80 == c&0xf0:
+		return d.decodeMap(int(c & 0x0f))
+	case 0x90 == c&0xf0:
+		return d.decodeArray(int(c & 0x0f))
+	case 0xa0 == c&0xe0:
+		return d.decodeString(int(c & 0x1f))
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.length(c - 0xc4)
+		if nil != err {
+			return nil, err
+		}
+		return d.next(n)
+	case 0xca:
+		u, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := d.uint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.uint(1 << (c - 0xcc))
+	case 0xd0:
+		u, err := d.uint(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := d.uint(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := d.uint(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := d.uint(8)
+		return int64(u), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.length(c - 0xd9)
+		if nil != err {
+			return nil, err
+		}
+		return d.decodeString(n)
+	case 0xdc, 0xdd:
+		n, err := d.length(c - 0xdc + 1)
+		if nil != err {
+			return nil, err
+		}
+		return d.decodeArray(n)
+	case 0xde, 0xdf:
+		n, err := d.length(c - 0xde + 1)
+		if nil != err {
+			return nil, err
+		}
+		return d.decodeMap(n)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported format 0x%02x", c)
+}
+
+func (d *msgpackDecoder) decodeArray(n int) (interface{}, error) {
+
+	// Every element takes at least one byte so a longer array can't be
+	// valid and mustn't be allocated.
+	if err := d.nest(n); nil != err {
+		return nil, err
+	}
+	defer d.unnest()
+	a := make([]interface{}, n)
+	for i := range a {
+		v, err := d.decode()
+		if nil != err {
+			return nil, err
+		}
+		a[i] = v
+	}
+	return a, nil
+}
+
+func (d *msgpackDecoder) decodeMap(n int) (interface{}, error) {
+
+	// Every key and value takes at least one byte.
+	if n > (len(d.buf)-d.i)/2 {
+		return nil, errMsgpackShort
+	}
+	if err := d.nest(n); nil != err {
+		return nil, err
+	}
+	defer d.unnest()
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, err := d.decode()
+		if nil != err {
+			return nil, err
+		}
+		v, err := d.decode()
+		if nil != err {
+			return nil, err
+		}
+		switch key := key.(type) {
+		case string:
+			m[key] = v
+		case []byte:
+			m[string(key)] = v
+		default:
+			m[fmt.Sprint(key)] = v
+		}
+	}
+	return m, nil
+}
+
+// nest checks that an array or map of n elements fits in the remaining input
+// and isn't nested too deeply and then descends into it.
+func (d *msgpackDecoder) nest(n int) error {
+	if d.depth >= msgpackMaxDepth {
+		return errMsgpackDepth
+	}
+	if n < 0 || n > len(d.buf)-d.i {
+		return errMsgpackShort
+	}
+	d.depth++
+	return nil
+}
+
+func (d *msgpackDecoder) unnest() {
+	d.depth--
+}
+
+func (d *msgpackDecoder) decodeString(n int) (interface{}, error) {
+	b, err := d.next(n)
+	return string(b), err
+}
+
+// length reads a 1-, 2-, or 4-byte length as selected by the low bits of a
+// format byte.
+func (d *msgpackDecoder) length(size byte) (int, error) {
+	u, err := d.uint(1 << size)
+	return int(u), err
+}
+
+func (d *msgpackDecoder) uint(n int) (uint64, error) {
+	b, err := d.next(n)
+	if nil != err {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.buf)-d.i < n {
+		return nil, errMsgpackShort
+	}
+	b := d.buf[d.i : d.i+n]
+	d.i += n
+	return b, nil
+}
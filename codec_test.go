@@ -0,0 +1,184 @@
+package tigertonic
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestFormRequestBody(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("POST", "http://example.com/foo", bytes.NewBufferString("foo=bar&n=3&tags=a&tags=b"))
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	Marshaled(func(u *url.URL, h http.Header, rq *testCodecRequest) (int, http.Header, *testResponse, error) {
+		if "bar" != rq.Foo || 3 != rq.N || !reflect.DeepEqual([]string{"a", "b"}, rq.Tags) {
+			t.Fatal(rq)
+		}
+		return http.StatusOK, nil, &testResponse{rq.Foo}, nil
+	}).ServeHTTP(w, r)
+	if http.StatusOK != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "{\"foo\":\"bar\"}\n" != w.Body.String() {
+		t.Fatal(w.Body.String())
+	}
+}
+
+func TestFormResponseBody(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	r.Header.Set("Accept", "application/x-www-form-urlencoded")
+	Marshaled(func(u *url.URL, h http.Header) (int, http.Header, *testCodecRequest, error) {
+		return http.StatusOK, nil, &testCodecRequest{"bar", 3, []string{"a", "b"}}, nil
+	}).ServeHTTP(w, r)
+	if "application/x-www-form-urlencoded" != w.Header().Get("Content-Type") {
+		t.Fatal(w.Header().Get("Content-Type"))
+	}
+	if "foo=bar&n=3&tags=a&tags=b" != w.Body.String() {
+		t.Fatal(w.Body.String())
+	}
+}
+
+func TestXMLResponseBody(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	r.Header.Set("Accept", "application/xml")
+	Marshaled(func(u *url.URL, h http.Header) (int, http.Header, *testResponse, error) {
+		return http.StatusOK, nil, &testResponse{"bar"}, nil
+	}).ServeHTTP(w, r)
+	if "application/xml" != w.Header().Get("Content-Type") {
+		t.Fatal(w.Header().Get("Content-Type"))
+	}
+	if "<testResponse><Foo>bar</Foo></testResponse>" != w.Body.String() {
+		t.Fatal(w.Body.String())
+	}
+}
+
+func TestXMLResponseBodyUnencodable(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	r.Header.Set("Accept", "application/xml")
+	Marshaled(func(u *url.URL, h http.Header) (int, http.Header, map[string]string, error) {
+		return http.StatusOK, nil, map[string]string{"foo": "bar"}, nil
+	}).ServeHTTP(w, r)
+	if http.StatusInternalServerError != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+}
+
+func TestBrowserAcceptPrefersJSON(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	r.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	Marshaled(func(u *url.URL, h http.Header) (int, http.Header, map[string]string, error) {
+		return http.StatusOK, nil, map[string]string{"foo": "bar"}, nil
+	}).ServeHTTP(w, r)
+	if http.StatusOK != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "application/json" != w.Header().Get("Content-Type") {
+		t.Fatal(w.Header().Get("Content-Type"))
+	}
+	if "{\"foo\":\"bar\"}\n" != w.Body.String() {
+		t.Fatal(w.Body.String())
+	}
+}
+
+func TestMessagePackRoundTrip(t *testing.T) {
+	rq := &testCodecRequest{"bar", -300, []string{"a", "b"}}
+	b := &bytes.Buffer{}
+	if err := (msgpackCodec{}).Encode(b, rq); nil != err {
+		t.Fatal(err)
+	}
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("POST", "http://example.com/foo", b)
+	r.Header.Set("Accept", "application/msgpack")
+	r.Header.Set("Content-Type", "application/msgpack")
+	Marshaled(func(u *url.URL, h http.Header, rq *testCodecRequest) (int, http.Header, *testCodecRequest, error) {
+		return http.StatusOK, nil, rq, nil
+	}).ServeHTTP(w, r)
+	if http.StatusOK != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+	if "application/msgpack" != w.Header().Get("Content-Type") {
+		t.Fatal(w.Header().Get("Content-Type"))
+	}
+	rs := &testCodecRequest{}
+	if err := (msgpackCodec{}).Decode(&w.Body, rs); nil != err {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rq, rs) {
+		t.Fatal(rs)
+	}
+}
+
+func TestMessagePackValues(t *testing.T) {
+	for _, v := range []interface{}{
+		nil,
+		true,
+		int64(127),
+		int64(-32),
+		int64(-129),
+		int64(70000),
+		int64(-1 << 40),
+		uint64(1 << 63),
+		1.5,
+		"",
+		string(make([]byte, 40)),
+		string(make([]byte, 300)),
+		[]interface{}{int64(1), "two", nil},
+		map[string]interface{}{"a": int64(1), "b": []interface{}{}},
+	} {
+		b := &bytes.Buffer{}
+		if err := (msgpackCodec{}).Encode(b, v); nil != err {
+			t.Fatal(err)
+		}
+		d := &msgpackDecoder{buf: b.Bytes()}
+		v2, err := d.decode()
+		if nil != err {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(v, v2) {
+			t.Errorf("%#v != %#v", v, v2)
+		}
+	}
+}
+
+func TestMessagePackMalicious(t *testing.T) {
+	for description, buf := range map[string][]byte{
+		"huge array":   {0xdd, 0xff, 0xff, 0xff, 0xff},
+		"huge map":     {0xdf, 0xff, 0xff, 0xff, 0xff, 0x00},
+		"deep nesting": bytes.Repeat([]byte{0x91}, msgpackMaxDepth+1),
+	} {
+		var v interface{}
+		if err := (msgpackCodec{}).Decode(bytes.NewReader(buf), &v); nil == err {
+			t.Fatal(description, v)
+		}
+	}
+	d := &msgpackDecoder{buf: bytes.Repeat([]byte{0x91}, msgpackMaxDepth+1)}
+	if _, err := d.decode(); errMsgpackDepth != err {
+		t.Fatal(err)
+	}
+}
+
+func TestUnregisteredMediaType(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("POST", "http://example.com/foo", bytes.NewBufferString("foo"))
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Content-Type", "application/cbor")
+	Marshaled(func(u *url.URL, h http.Header, rq *testRequest) (int, http.Header, *testResponse, error) {
+		return http.StatusNoContent, nil, nil, nil
+	}).ServeHTTP(w, r)
+	if http.StatusUnsupportedMediaType != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+}
+
+type testCodecRequest struct {
+	Foo  string   `json:"foo"`
+	N    int      `json:"n"`
+	Tags []string `json:"tags"`
+}
@@ -20,6 +20,7 @@ func RegisterConfigExt(ext string, f configParser) {
 }
 
 func init() {
+	RegisterConfigExt(".htpasswd", ConfigureHtpasswd)
 	RegisterConfigExt(".json", ConfigureJSON)
 }
 
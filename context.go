@@ -1,22 +1,26 @@
 package tigertonic
 
 import (
+	"context"
 	"net/http"
 	"reflect"
-	"sync"
 )
 
-var (
-	contexts map[*http.Request]interface{}
-	mutex    sync.Mutex
-)
+// contextKey is the key under which ContextHandler stores the per-request
+// context object in each request's context.Context.
+type contextKey struct{}
 
 // Context returns the request context as an interface{} given a pointer
-// to the request itself.
+// to the request itself.  It's carried by the request's context.Context so
+// it survives middleware that calls r.WithContext.
 func Context(r *http.Request) interface{} {
-	mutex.Lock()
-	defer mutex.Unlock()
-	return contexts[r]
+	return ContextValue(r.Context())
+}
+
+// ContextValue returns the request context as an interface{} given the
+// request's context.Context, as passed to Marshaled functions that take one.
+func ContextValue(ctx context.Context) interface{} {
+	return ctx.Value(contextKey{})
 }
 
 // ContextHandler is an http.Handler that associates a context object of
@@ -37,26 +41,9 @@ func WithContext(handler http.Handler, i interface{}) *ContextHandler {
 	}
 }
 
-// ServeHTTP adds and removes the per-request context and calls the wrapped
-// http.Handler in between.
+// ServeHTTP adds the per-request context to the request's context.Context
+// and calls the wrapped http.Handler.
 func (ch *ContextHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	ch.add(r)
-	defer ch.remove(r)
-	ch.handler.ServeHTTP(w, r)
-}
-
-func (ch *ContextHandler) add(r *http.Request) {
-	mutex.Lock()
-	defer mutex.Unlock()
-	contexts[r] = reflect.New(ch.t).Interface()
-}
-
-func (ch *ContextHandler) remove(r *http.Request) {
-	mutex.Lock()
-	defer mutex.Unlock()
-	delete(contexts, r)
-}
-
-func init() {
-	contexts = make(map[*http.Request]interface{})
+	ctx := context.WithValue(r.Context(), contextKey{}, reflect.New(ch.t).Interface())
+	ch.handler.ServeHTTP(w, r.WithContext(ctx))
 }
@@ -1,38 +1,58 @@
 package tigertonic
 
 import (
+	"context"
 	"net/http"
 	"testing"
 )
 
 func TestContext(t *testing.T) {
-	ch := make(chan bool)
+	var contexts []*testContext
 	mux := NewTrieServeMux()
-	mux.HandleFunc("GET", "/1", func(w http.ResponseWriter, r *http.Request) {
-		if 1 != len(contexts) {
-			t.Error(contexts)
+	mux.HandleFunc("GET", "/", func(w http.ResponseWriter, r *http.Request) {
+		c, ok := Context(r).(*testContext)
+		if !ok {
+			t.Fatal(Context(r))
 		}
-		<-ch
-		w.WriteHeader(http.StatusNoContent)
-	})
-	mux.HandleFunc("GET", "/2", func(w http.ResponseWriter, r *http.Request) {
-		if 2 != len(contexts) {
-			t.Error(contexts)
-		}
-		ch <- true
+		contexts = append(contexts, c)
 		w.WriteHeader(http.StatusNoContent)
 	})
 	handler := WithContext(mux, testContext{})
-	go func() {
+	for i := 0; i < 2; i++ {
 		w := &testResponseWriter{}
-		r, _ := http.NewRequest("GET", "http://example.com/1", nil)
+		r, _ := http.NewRequest("GET", "http://example.com/", nil)
 		handler.ServeHTTP(w, r)
-		w2 := &testResponseWriter{}
-		r2, _ := http.NewRequest("GET", "http://example.com/2", nil)
-		handler.ServeHTTP(w2, r2)
-	}()
+		if http.StatusNoContent != w.StatusCode {
+			t.Fatal(w.StatusCode)
+		}
+		if nil != Context(r) {
+			t.Fatal(Context(r))
+		}
+	}
+	if 2 != len(contexts) || contexts[0] == contexts[1] {
+		t.Fatal(contexts)
+	}
 }
 
+func TestContextWithContext(t *testing.T) {
+	handler := WithContext(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Context(r).(*testContext).Foo = "bar"
+		r = r.WithContext(context.WithValue(r.Context(), testContextKey{}, "quux"))
+		if "bar" != Context(r).(*testContext).Foo {
+			t.Fatal(Context(r))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}), testContext{})
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/", nil)
+	handler.ServeHTTP(w, r)
+	if http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+}
+
+type testContextKey struct{}
+
 type testContext struct {
 	Foo string
 	Bar int
@@ -3,7 +3,10 @@ package tigertonic
 import (
 	"log"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -18,16 +21,20 @@ const (
 	CORSAllowHeaders     string = "Access-Control-Allow-Headers"
 	CORSExposeHeaders    string = "Access-Control-Expose-Headers"
 	CORSAllowCredentials string = "Access-Control-Allow-Credentials"
+	CORSMaxAge           string = "Access-Control-Max-Age"
 )
 
 // CORSHandler wraps an http.Handler while correctly handling CORS related
 // functionality, such as Origin headers. It also allows tigertonic core to
-// correctly respond to OPTIONS headers for CORS-enabled endpoints
+// correctly respond to OPTIONS headers for CORS-enabled endpoints, even when
+// the CORSHandler is wrapped in other middleware.
 type CORSHandler struct {
 	http.Handler
 	origins                     map[string]bool
+	patterns                    []*regexp.Regexp
 	allowHeaders, exposeHeaders string
 	allowCredentials            bool
+	maxAge                      string
 }
 
 func (self *CORSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -36,19 +43,32 @@ func (self *CORSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // HandleCORS checks for CORS related request headers and writes the
-// matching response headers for both OPTIONS and regular requests
+// matching response headers for both OPTIONS and regular requests. Requests
+// from origins that aren't allowed get no CORS headers at all. Unless every
+// origin is allowed, the response varies by Origin.
 func (self *CORSHandler) HandleCORS(w http.ResponseWriter, r *http.Request) {
+	if !self.allowsAnyOrigin() {
+		addVary(w.Header(), CORSRequestOrigin)
+	}
 	if requestOrigin := r.Header.Get(CORSRequestOrigin); requestOrigin != "" {
 		allowedOrigin := self.allowedOrigin(requestOrigin)
+		if allowedOrigin == "" {
+			return
+		}
 		if self.allowCredentials && allowedOrigin == requestOrigin {
 			w.Header().Set(CORSAllowCredentials, self.allowsCredentials())
 		}
 		w.Header().Set(CORSAllowOrigin, allowedOrigin)
 	}
-	if requestHeaders := r.Header.Get(CORSRequestHeaders); requestHeaders != "" {
+	if requestHeaders := r.Header.Get(CORSRequestHeaders); requestHeaders != "" && self.allowHeaders != "" {
 		w.Header().Set(CORSAllowHeaders, self.allowedHeaders())
 	}
-	w.Header().Set(CORSExposeHeaders, self.exposedHeaders())
+	if self.exposeHeaders != "" {
+		w.Header().Set(CORSExposeHeaders, self.exposedHeaders())
+	}
+	if r.Method == "OPTIONS" && r.Header.Get(CORSRequestMethod) != "" && self.maxAge != "" {
+		w.Header().Set(CORSMaxAge, self.maxAge)
+	}
 }
 
 func (self *CORSHandler) allowsCredentials() string {
@@ -58,17 +78,36 @@ func (self *CORSHandler) allowsCredentials() string {
 	return ""
 }
 
+// allowsAnyOrigin returns true if the configuration allows every origin, in
+// which case responses don't depend on the Origin header.
+func (self *CORSHandler) allowsAnyOrigin() bool {
+	return len(self.origins) == 1 && self.origins["*"]
+}
+
+// allowsOrigin returns true if requests from the given origin may see CORS
+// headers. Requests without an Origin header aren't cross-origin so they're
+// always allowed.
+func (self *CORSHandler) allowsOrigin(requestOrigin string) bool {
+	return requestOrigin == "" || self.allowedOrigin(requestOrigin) != ""
+}
+
 // allowedOrigin checks if the requested origin is allowed by the configuration
-// and returns a value that makes sense in context. It's less straight forward
-// than allowedHeaders due to browser quirks. See the following excellent doc
-// for more information: http://enable-cors.org/server_nginx.html
+// and returns a value that makes sense in context or the empty string if it's
+// not allowed. It's less straight forward than allowedHeaders due to browser
+// quirks. See the following excellent doc for more information:
+// http://enable-cors.org/server_nginx.html
 func (self *CORSHandler) allowedOrigin(requestOrigin string) string {
-	if len(self.origins) == 1 && self.origins["*"] {
+	if self.allowsAnyOrigin() {
 		return "*"
 	} else if self.origins[requestOrigin] {
 		return requestOrigin
 	}
-	return "null"
+	for _, pattern := range self.patterns {
+		if pattern.MatchString(requestOrigin) {
+			return requestOrigin
+		}
+	}
+	return ""
 }
 
 // allowedHeaders simply returns the headers permitted on requests
@@ -86,30 +125,54 @@ func (self *CORSHandler) exposedHeaders() string {
 // might wrap a handler in a call to Timed() or Logged().
 type CORSBuilder struct {
 	origins                     map[string]bool
+	patterns                    []*regexp.Regexp
 	allowHeaders, exposeHeaders []string
 	allowCredentials            bool
+	maxAge                      time.Duration
 }
 
 func NewCORSBuilder() *CORSBuilder {
-	return &CORSBuilder{map[string]bool{}, []string{}, []string{}, false}
+	return &CORSBuilder{map[string]bool{}, nil, []string{}, []string{}, false, 0}
 }
 
 // AddAllowedOrigins sets the list of  domain for which cross-origin
-// requests are allowed
+// requests are allowed. An origin may contain * in place of one or more
+// host name labels, as in https://*.example.com, to allow every origin that
+// matches.
 func (self *CORSBuilder) AddAllowedOrigins(origins ...string) *CORSBuilder {
 	for _, origin := range origins {
 		if origin == "*" {
-			if len(origins)+len(self.origins) > 1 {
+			if len(origins)+len(self.origins)+len(self.patterns) > 1 {
 				log.Println("WARNING: Setting CORS allowed origin * as well as other explicit origins. * will cause all origins to be accepted, and the rest of the list will be ignored. This is probably not what you want.")
 			}
 			self.origins = map[string]bool{"*": true}
+			self.patterns = nil
 			break
 		}
+		if self.origins["*"] {
+			continue
+		}
+		if strings.Contains(origin, "*") {
+			self.patterns = append(self.patterns, regexp.MustCompile(
+				"^"+strings.Replace(regexp.QuoteMeta(origin), `\*`, corsWildcard, -1)+"$",
+			))
+			continue
+		}
 		self.origins[origin] = true
 	}
 	return self
 }
 
+// AddAllowedOriginPatterns adds regular expressions that must match the
+// entire origin for cross-origin requests to be allowed. It panics if a
+// pattern doesn't compile.
+func (self *CORSBuilder) AddAllowedOriginPatterns(patterns ...string) *CORSBuilder {
+	for _, pattern := range patterns {
+		self.patterns = append(self.patterns, regexp.MustCompile("^(?:"+pattern+")$"))
+	}
+	return self
+}
+
 func (self *CORSBuilder) AddAllowedHeaders(headers ...string) *CORSBuilder {
 	self.allowHeaders = append(self.allowHeaders, headers...)
 	return self
@@ -125,6 +188,34 @@ func (self *CORSBuilder) AddExposedHeaders(headers ...string) *CORSBuilder {
 	return self
 }
 
+// SetMaxAge sets how long browsers may cache the response to a preflight
+// request, which is sent in the Access-Control-Max-Age header.
+func (self *CORSBuilder) SetMaxAge(maxAge time.Duration) *CORSBuilder {
+	self.maxAge = maxAge
+	return self
+}
+
 func (self *CORSBuilder) Build(handler http.Handler) *CORSHandler {
-	return &CORSHandler{handler, self.origins, strings.Join(self.allowHeaders, ", "), strings.Join(self.exposeHeaders, ", "), self.allowCredentials}
+	var maxAge string
+	if self.maxAge > 0 {
+		maxAge = strconv.Itoa(int(self.maxAge / time.Second))
+	}
+	return &CORSHandler{handler, self.origins, self.patterns, strings.Join(self.allowHeaders, ", "), strings.Join(self.exposeHeaders, ", "), self.allowCredentials, maxAge}
+}
+
+// corsWildcard is what * in an allowed origin matches: one or more host
+// name labels but never a scheme, port, or path.
+const corsWildcard = `[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*`
+
+// addVary adds the given header name to the Vary header unless it's already
+// there.
+func addVary(header http.Header, name string) {
+	for _, value := range header["Vary"] {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), name) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
 }
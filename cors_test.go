@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/url"
 	"testing"
+	"time"
 )
 
 type TestResponse struct {
@@ -41,9 +42,15 @@ func TestCORSOPTIONS(t *testing.T) {
 	if http.StatusOK != w.StatusCode {
 		t.Fatal(w.StatusCode)
 	}
-	if "null" != w.Header().Get(CORSAllowOrigin) {
+	if "" != w.Header().Get(CORSAllowOrigin) {
 		t.Fatal(w.Header().Get(CORSAllowOrigin))
 	}
+	if "" != w.Header().Get(CORSAllowMethods) {
+		t.Fatal(w.Header().Get(CORSAllowMethods))
+	}
+	if "Origin" != w.Header().Get("Vary") {
+		t.Fatal(w.Header().Get("Vary"))
+	}
 
 	// requesting unsecured/wildcard resource with invalid domain
 	w = &testResponseWriter{}
@@ -244,3 +251,122 @@ func TestCORSCredentials(t *testing.T) {
 		t.Fatal(w.Header().Get(CORSAllowCredentials))
 	}
 }
+
+func TestCORSOriginPatterns(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.Handle("GET", "/foo", NewCORSBuilder().AddAllowedOrigins("https://*.example.com").AddAllowCredentials(true).Build(Marshaled(get)))
+	mux.Handle("GET", "/bar", NewCORSBuilder().AddAllowedOriginPatterns(`https?://localhost(:\d+)?`).Build(Marshaled(get)))
+
+	for path, origins := range map[string]map[string]bool{
+		"/foo": {
+			"https://www.example.com":         true,
+			"https://a.b.example.com":         true,
+			"https://example.com":             false,
+			"http://www.example.com":          false,
+			"https://www.example.com.evil.io": false,
+			"https://evil.io/.example.com":    false,
+		},
+		"/bar": {
+			"http://localhost":       true,
+			"https://localhost:8080": true,
+			"http://localhost.evil":  false,
+		},
+	} {
+		for origin, allowed := range origins {
+			w := &testResponseWriter{}
+			r, _ := http.NewRequest("GET", "http://example.com"+path, nil)
+			r.Header.Set("Accept", "application/json")
+			r.Header.Set(CORSRequestOrigin, origin)
+			mux.ServeHTTP(w, r)
+			if http.StatusOK != w.StatusCode {
+				t.Fatal(w.StatusCode)
+			}
+			if allowed && origin != w.Header().Get(CORSAllowOrigin) {
+				t.Fatal(origin, w.Header())
+			}
+			if !allowed && "" != w.Header().Get(CORSAllowOrigin) {
+				t.Fatal(origin, w.Header())
+			}
+			if "Origin" != w.Header().Get("Vary") {
+				t.Fatal(origin, w.Header())
+			}
+		}
+	}
+
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set(CORSRequestOrigin, "https://www.example.com")
+	mux.ServeHTTP(w, r)
+	if "true" != w.Header().Get(CORSAllowCredentials) {
+		t.Fatal(w.Header())
+	}
+}
+
+func TestCORSVary(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.Handle("GET", "/foo", NewCORSBuilder().AddAllowedOrigins("*").Build(Marshaled(get)))
+	mux.Handle("GET", "/bar", NewCORSBuilder().AddAllowedOrigins("http://gooddomain.com").Build(Marshaled(get)))
+
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set(CORSRequestOrigin, "http://gooddomain.com")
+	mux.ServeHTTP(w, r)
+	if "" != w.Header().Get("Vary") {
+		t.Fatal(w.Header())
+	}
+
+	// no Origin, but a cache must not serve this to a cross-origin request
+	w = &testResponseWriter{}
+	r, _ = http.NewRequest("GET", "http://example.com/bar", nil)
+	r.Header.Set("Accept", "application/json")
+	mux.ServeHTTP(w, r)
+	if "Origin" != w.Header().Get("Vary") {
+		t.Fatal(w.Header())
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.Handle("GET", "/foo", Logged(
+		NewCORSBuilder().AddAllowedOrigins("https://*.example.com").AddAllowedHeaders("X-Pizza-Fax").SetMaxAge(10*time.Minute).Build(Marshaled(get)),
+		nil,
+	))
+	mux.Handle("POST", "/foo", NotFoundHandler{})
+
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	r.Header.Set(CORSRequestOrigin, "https://www.example.com")
+	r.Header.Set(CORSRequestMethod, "GET")
+	r.Header.Set(CORSRequestHeaders, "X-Pizza-Fax")
+	mux.ServeHTTP(w, r)
+	if http.StatusOK != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "GET, HEAD, OPTIONS, POST" != w.Header().Get(CORSAllowMethods) {
+		t.Fatal(w.Header())
+	}
+	if "https://www.example.com" != w.Header().Get(CORSAllowOrigin) {
+		t.Fatal(w.Header())
+	}
+	if "X-Pizza-Fax" != w.Header().Get(CORSAllowHeaders) {
+		t.Fatal(w.Header())
+	}
+	if "600" != w.Header().Get(CORSMaxAge) {
+		t.Fatal(w.Header())
+	}
+
+	// disallowed origin gets no CORS headers
+	w = &testResponseWriter{}
+	r.Header.Set(CORSRequestOrigin, "https://evil.io")
+	mux.ServeHTTP(w, r)
+	if http.StatusOK != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	for _, name := range []string{CORSAllowOrigin, CORSAllowMethods, CORSAllowHeaders, CORSMaxAge} {
+		if "" != w.Header().Get(name) {
+			t.Fatal(name, w.Header())
+		}
+	}
+}
@@ -11,30 +11,16 @@ import (
 	"unicode/utf8"
 )
 
+// acceptJSON reports whether the request prefers a JSON representation to
+// a plaintext one.
 func acceptJSON(r *http.Request) bool {
-	accept := r.Header.Get("Accept")
-	if "" == accept {
-		return true
-	}
-	return strings.Contains(accept, "*/*") || strings.Contains(accept, "application/*") || strings.Contains(accept, "application/json")
+	return "application/json" == NegotiateContentType(r, "application/json", "text/plain")
 }
 
+// acceptContentType reports whether the request's Accept header allows the
+// given content type at all.
 func acceptContentType(r *http.Request, contentType string) bool {
-	accept := r.Header.Get("Accept")
-	if "" == accept {
-		return true
-	}
-	if strings.Contains(accept, "*/*") {
-		return true
-	}
-	if strings.Contains(accept, contentType) {
-		return true
-	}
-	typeParts := strings.Split(contentType, "/")
-	if len(typeParts) < 2 {
-		return false
-	}
-	return strings.Contains(accept, fmt.Sprintf("%s/*", typeParts[0]))
+	return "" != NegotiateContentType(r, contentType)
 }
 
 func errorName(err error, fallback string) string {
@@ -102,10 +88,14 @@ func (d defaultErrorWriter) WriteJSONError(w http.ResponseWriter, err error) {
 		}
 	}
 
-	if jsonErr := json.NewEncoder(w).Encode(map[string]string{
+	body := map[string]interface{}{
 		"description": err.Error(),
 		"error":       errName,
-	}); nil != jsonErr {
+	}
+	if validationErr, ok := err.(ValidationError); ok {
+		body["fields"] = validationErr
+	}
+	if jsonErr := json.NewEncoder(w).Encode(body); nil != jsonErr {
 		log.Printf("Error marshalling error response into JSON output: %s", jsonErr)
 	}
 }
@@ -1,5 +1,12 @@
 package main
 
+import "github.com/rcrowley/go-tigertonic"
+
+type MyParams struct {
+	tigertonic.Params
+	ID string `path:"id"`
+}
+
 type MyRequest struct {
 	ID    string      `json:"id"`
 	Stuff interface{} `json:"stuff"`
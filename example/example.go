@@ -1,6 +1,7 @@
 package main
 
 import (
+	stdcontext "context"
 	"errors"
 	_ "expvar" // Imported for side-effect of handling /debug/vars.
 	"flag"
@@ -11,8 +12,10 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/rcrowley/go-metrics"
 	"github.com/rcrowley/go-tigertonic"
@@ -24,6 +27,7 @@ var (
 	config = flag.String("config", "", "pathname of JSON configuration file")
 	listen = flag.String("listen", "127.0.0.1:8000", "listen address")
 
+	health     *tigertonic.HealthChecks
 	hMux       tigertonic.HostServeMux
 	mux, nsMux *tigertonic.TrieServeMux
 )
@@ -116,17 +120,33 @@ func init() {
 		}),
 	)
 
+	// Example use of a metrics.Registry's Prometheus output.
+	mux.Handle("GET", "/metrics", &tigertonic.PrometheusHandler{})
+
 	// Example use of the version endpoint.
 	mux.Handle("GET", "/version", tigertonic.Version(Version))
 
+	// Example use of health checks.  Readiness fails as soon as the server
+	// begins to stop, as arranged in main below.
+	health = tigertonic.NewHealthChecks()
+	health.Register("goroutines", 0, func(stdcontext.Context) error {
+		if 10000 < runtime.NumGoroutine() {
+			return errors.New("too many goroutines")
+		}
+		return nil
+	})
+	mux.Handle("GET", "/healthz", health.Healthz())
+	mux.Handle("GET", "/readyz", health.Readyz())
+
 	// Example use of namespaces.
 	nsMux = tigertonic.NewTrieServeMux()
 	nsMux.HandleNamespace("", mux)
 	nsMux.HandleNamespace("/1.0", mux)
 
-	// Example use of virtual hosts.
+	// Example use of virtual hosts and of TimedByRoute to time every route
+	// without naming each one.
 	hMux = tigertonic.NewHostServeMux()
-	hMux.Handle("example.com", nsMux)
+	hMux.Handle("example.com", tigertonic.TimedByRoute(nsMux, "route", nil))
 
 	// Register http.DefaultServeMux on a subdomain for access to
 	// standard library features such as /debug/pprof and /debug/vars
@@ -161,11 +181,16 @@ func main() {
 			// wherever it appears.
 			tigertonic.Logged(
 
-				// Example use of WithContext, which is required in order to
-				// use Context within any handlers.  The second argument is a
-				// zero value of the type to be used for all actual request
-				// contexts.
-				tigertonic.WithContext(hMux, context{}),
+				// Example use of Recovered to respond 500 with the request ID
+				// logged above when a handler panics.
+				tigertonic.Recovered(
+
+					// Example use of WithContext, which is required in order
+					// to use Context within any handlers.  The second
+					// argument is a zero value of the type to be used for all
+					// actual request contexts.
+					tigertonic.WithContext(hMux, context{}),
+				),
 
 				func(s string) string {
 					return strings.Replace(s, "SECRET", "REDACTED", -1)
@@ -176,15 +201,26 @@ func main() {
 		),
 	)
 
+	// Example use of HealthChecks.Watch to fail readiness checks while the
+	// server stops, giving load balancers five seconds to notice.
+	health.Watch(server, 5*time.Second)
+
+	// Example use of ReexecOn to restart without dropping connections by
+	// passing the listening socket to a new process on SIGUSR2.
+	server.ReexecOn(syscall.SIGUSR2)
+
+	// Example use of TLS with the certificate and private key reloaded on
+	// SIGHUP.
+	if "" != *cert && "" != *key {
+		if err := server.TLS(*cert, *key); nil != err {
+			log.Fatalln(err)
+		}
+		server.ReloadTLSOn(nil, syscall.SIGHUP)
+	}
+
 	// Example use of server.Close to stop gracefully.
 	go func() {
-		var err error
-		if "" != *cert && "" != *key {
-			err = server.ListenAndServeTLS(*cert, *key)
-		} else {
-			err = server.ListenAndServe()
-		}
-		if nil != err {
+		if err := server.ListenAndServe(); nil != err {
 			log.Println(err)
 		}
 	}()
@@ -208,11 +244,11 @@ func create(u *url.URL, h http.Header, rq *MyRequest) (int, http.Header, *MyResp
 }
 
 // GET /stuff/{id}
-func get(u *url.URL, h http.Header, _ interface{}) (int, http.Header, *MyResponse, error) {
-	return http.StatusOK, nil, &MyResponse{u.Query().Get("id"), "STUFF"}, nil
+func get(u *url.URL, h http.Header, p *MyParams) (int, http.Header, *MyResponse, error) {
+	return http.StatusOK, nil, &MyResponse{p.ID, "STUFF"}, nil
 }
 
 // POST /stuff/{id}
-func update(u *url.URL, h http.Header, rq *MyRequest) (int, http.Header, *MyResponse, error) {
-	return http.StatusAccepted, nil, &MyResponse{u.Query().Get("id"), "STUFF"}, nil
+func update(u *url.URL, h http.Header, p *MyParams, rq *MyRequest) (int, http.Header, *MyResponse, error) {
+	return http.StatusAccepted, nil, &MyResponse{p.ID, "STUFF"}, nil
 }
@@ -30,7 +30,7 @@ func TestGet(t *testing.T) {
 	code, _, response, err := get(
 		mocking.URL(hMux, "GET", "http://example.com/1.0/stuff/ID"),
 		mocking.Header(nil),
-		nil,
+		&MyParams{ID: "ID"},
 	)
 	if nil != err {
 		t.Fatal(err)
@@ -59,6 +59,7 @@ func TestUpdate(t *testing.T) {
 	code, _, response, err := update(
 		mocking.URL(hMux, "POST", "http://example.com/1.0/stuff/ID"),
 		mocking.Header(nil),
+		&MyParams{ID: "ID"},
 		&MyRequest{"ID", "STUFF"},
 	)
 	if nil != err {
@@ -0,0 +1,347 @@
+package tigertonic
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HashedCredentials maps usernames to password hashes in any format
+// htpasswd(1) writes - bcrypt, Apache's MD5 ($apr1$), or SHA-1 ({SHA}) -
+// or in the PHC string format of argon2id or argon2i.  It may be read from
+// a JSON file or an htpasswd file with a .htpasswd extension by Configure.
+type HashedCredentials map[string]string
+
+// HTTPBasicAuthHashed returns an http.Handler that conditionally calls
+// another http.Handler if the request includes an Authorization header with
+// a username and password that match the hashed credentials.  Otherwise,
+// respond 401 Unauthorized.
+func HTTPBasicAuthHashed(
+	credentials HashedCredentials,
+	realm string,
+	h http.Handler,
+) FirstHandler {
+	return HTTPBasicAuthFunc(credentials.Verify, realm, h)
+}
+
+// Verify returns nil if the password matches the username's hash.  The
+// password for an unknown username is compared against another username's
+// hash and rejected so that, as long as every hash uses the same scheme and
+// cost, usernames can't be discovered by timing responses.
+func (credentials HashedCredentials) Verify(username, password string) error {
+	hash, ok := credentials[username]
+	if !ok {
+		for _, decoy := range credentials {
+			verifyPasswordHash(decoy, password)
+			break
+		}
+		return errors.New("unauthorized")
+	}
+	if !verifyPasswordHash(hash, password) {
+		return errors.New("unauthorized")
+	}
+	return nil
+}
+
+func verifyPasswordHash(hash, password string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return nil == bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	case strings.HasPrefix(hash, "$argon2id$"), strings.HasPrefix(hash, "$argon2i$"):
+		return verifyArgon2(hash, password)
+	case strings.HasPrefix(hash, "$apr1$"):
+		salt := strings.SplitN(hash[6:], "$", 2)[0]
+		return 1 == subtle.ConstantTimeCompare([]byte(hash), []byte(apr1(password, salt)))
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		return 1 == subtle.ConstantTimeCompare(
+			[]byte(hash[5:]),
+			[]byte(base64.StdEncoding.EncodeToString(sum[:])),
+		)
+	}
+	return false
+}
+
+// Limits on the argon2 parameters parseArgon2 accepts so a malformed or
+// hostile hash can't panic or exhaust the server.  Memory is in KiB.
+const (
+	argon2MaxMemory = 1 << 20
+	argon2MaxTime   = 64
+	argon2MinKeyLen = 4
+	argon2MaxKeyLen = 1024
+)
+
+// argon2Hash is a hash in the PHC string format, as in
+// $argon2id$v=19$m=65536,t=3,p=4$salt$hash.
+type argon2Hash struct {
+	id                 bool
+	memory, iterations uint32
+	threads            uint8
+	salt, key          []byte
+}
+
+// parseArgon2 parses a hash in the PHC string format and returns an error if
+// it's malformed or its parameters are out of bounds.
+func parseArgon2(hash string) (*argon2Hash, error) {
+	fields := strings.Split(hash, "$")
+	if 6 != len(fields) || "v=19" != fields[2] {
+		return nil, errors.New("malformed argon2 hash")
+	}
+	h := &argon2Hash{id: "argon2id" == fields[1]}
+	if _, err := fmt.Sscanf(fields[3], "m=%d,t=%d,p=%d", &h.memory, &h.iterations, &h.threads); nil != err {
+		return nil, fmt.Errorf("malformed argon2 parameters: %s", err)
+	}
+	if 0 == h.threads {
+		return nil, errors.New("argon2 parallelism must be at least 1")
+	}
+	if 0 == h.iterations || h.iterations > argon2MaxTime {
+		return nil, fmt.Errorf("argon2 time %d isn't between 1 and %d", h.iterations, argon2MaxTime)
+	}
+	if h.memory < 8*uint32(h.threads) || h.memory > argon2MaxMemory {
+		return nil, fmt.Errorf("argon2 memory %d isn't between %d and %d", h.memory, 8*uint32(h.threads), argon2MaxMemory)
+	}
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(fields[4]); nil != err {
+		return nil, err
+	}
+	if h.key, err = base64.RawStdEncoding.DecodeString(fields[5]); nil != err {
+		return nil, err
+	}
+	if len(h.key) < argon2MinKeyLen || len(h.key) > argon2MaxKeyLen {
+		return nil, fmt.Errorf("argon2 key length %d isn't between %d and %d", len(h.key), argon2MinKeyLen, argon2MaxKeyLen)
+	}
+	return h, nil
+}
+
+// verifyArgon2 verifies a password against a hash in the PHC string format.
+// Malformed hashes never match.
+func verifyArgon2(hash, password string) bool {
+	h, err := parseArgon2(hash)
+	if nil != err {
+		return false
+	}
+	var derived []byte
+	if h.id {
+		derived = argon2.IDKey([]byte(password), h.salt, h.iterations, h.memory, h.threads, uint32(len(h.key)))
+	} else {
+		derived = argon2.Key([]byte(password), h.salt, h.iterations, h.memory, h.threads, uint32(len(h.key)))
+	}
+	return 1 == subtle.ConstantTimeCompare(h.key, derived)
+}
+
+// apr1 hashes a password with Apache's variant of the MD5-based crypt(3).
+func apr1(password, salt string) string {
+	const magic = "$apr1$"
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	pw := []byte(password)
+	alt := md5.Sum([]byte(password + salt + password))
+	h := md5.New()
+	h.Write([]byte(password + magic + salt))
+	for i := len(pw); i > 0; i -= 16 {
+		if i > 16 {
+			h.Write(alt[:])
+		} else {
+			h.Write(alt[:i])
+		}
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if 0 != i&1 {
+			h.Write([]byte{0})
+		} else {
+			h.Write(pw[:1])
+		}
+	}
+	sum := h.Sum(nil)
+	for i := 0; i < 1000; i++ {
+		h := md5.New()
+		if 0 != i&1 {
+			h.Write(pw)
+		} else {
+			h.Write(sum)
+		}
+		if 0 != i%3 {
+			h.Write([]byte(salt))
+		}
+		if 0 != i%7 {
+			h.Write(pw)
+		}
+		if 0 != i&1 {
+			h.Write(sum)
+		} else {
+			h.Write(pw)
+		}
+		sum = h.Sum(nil)
+	}
+	const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	encoded := make([]byte, 0, 22)
+	to64 := func(v uint, n int) {
+		for ; n > 0; n-- {
+			encoded = append(encoded, itoa64[v&0x3f])
+			v >>= 6
+		}
+	}
+	for _, i := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		to64(uint(sum[i[0]])<<16|uint(sum[i[1]])<<8|uint(sum[i[2]]), 4)
+	}
+	to64(uint(sum[11]), 2)
+	return magic + salt + "$" + string(encoded)
+}
+
+// ConfigureHtpasswd reads the given htpasswd file into the given
+// *HashedCredentials.  For convenient use with the flags package, an empty
+// pathname is not considered an error.
+func ConfigureHtpasswd(pathname string, i interface{}) error {
+	if "" == pathname {
+		return nil
+	}
+	credentials, ok := i.(*HashedCredentials)
+	if !ok {
+		return fmt.Errorf("%s must be read into a *HashedCredentials, not %T", pathname, i)
+	}
+	f, err := os.Open(pathname)
+	if nil != err {
+		return err
+	}
+	defer f.Close()
+	if nil == *credentials {
+		*credentials = make(HashedCredentials)
+	}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if "" == line || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if -1 == i {
+			return fmt.Errorf("%s:%d: malformed line", pathname, n)
+		}
+		hash := line[i+1:]
+		if strings.HasPrefix(hash, "$argon2id$") || strings.HasPrefix(hash, "$argon2i$") {
+			if _, err := parseArgon2(hash); nil != err {
+				return fmt.Errorf("%s:%d: %s", pathname, n, err)
+			}
+		}
+		(*credentials)[line[:i]] = hash
+	}
+	return scanner.Err()
+}
+
+// Lockout refuses to check passwords for usernames that have failed too
+// many times in a row until some time has passed, to slow down guessing.
+// It remembers failures for at most lockoutMaxUsernames usernames at once,
+// forgetting the least recent when guesses at many usernames would exceed
+// that.
+type Lockout struct {
+	attempts int
+	duration time.Duration
+	failures map[string]*lockoutFailures
+	max      int
+	mu       sync.Mutex
+	swept    time.Time
+}
+
+const lockoutMaxUsernames = 10000
+
+type lockoutFailures struct {
+	n    int
+	last time.Time
+}
+
+// NewLockout makes a Lockout that locks a username out for the given
+// duration after the given number of consecutive failures.
+func NewLockout(attempts int, duration time.Duration) *Lockout {
+	return &Lockout{
+		attempts: attempts,
+		duration: duration,
+		failures: make(map[string]*lockoutFailures),
+		max:      lockoutMaxUsernames,
+		swept:    time.Now(),
+	}
+}
+
+// Func wraps a function like HashedCredentials.Verify, for use with
+// HTTPBasicAuthFunc, so that it's not called for usernames that are locked
+// out and its failures are counted.
+func (l *Lockout) Func(f func(string, string) error) func(string, string) error {
+	return func(username, password string) error {
+		if l.locked(username) {
+			return errors.New("too many failed attempts")
+		}
+		err := f(username, password)
+		l.record(username, nil == err)
+		return err
+	}
+}
+
+func (l *Lockout) locked(username string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	failures, ok := l.failures[username]
+	return ok && failures.n >= l.attempts && time.Since(failures.last) < l.duration
+}
+
+func (l *Lockout) record(username string, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if now.Sub(l.swept) > l.duration {
+		l.sweep(now)
+	}
+	if ok {
+		delete(l.failures, username)
+		return
+	}
+	failures, found := l.failures[username]
+	if !found && len(l.failures) >= l.max {
+		l.sweep(now)
+		l.evict()
+	}
+	if !found || now.Sub(failures.last) >= l.duration {
+		failures = &lockoutFailures{}
+		l.failures[username] = failures
+	}
+	failures.n++
+	failures.last = now
+}
+
+// evict forgets the usernames that failed least recently until there's room
+// for one more.  The caller must hold l.mu.
+func (l *Lockout) evict() {
+	for len(l.failures) >= l.max {
+		var (
+			oldest string
+			last   time.Time
+		)
+		for u, failures := range l.failures {
+			if last.IsZero() || failures.last.Before(last) {
+				oldest, last = u, failures.last
+			}
+		}
+		delete(l.failures, oldest)
+	}
+}
+
+// sweep forgets failures that are too old to lock anyone out.  The caller
+// must hold l.mu.
+func (l *Lockout) sweep(now time.Time) {
+	for u, failures := range l.failures {
+		if now.Sub(failures.last) >= l.duration {
+			delete(l.failures, u)
+		}
+	}
+	l.swept = now
+}
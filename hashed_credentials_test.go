@@ -0,0 +1,154 @@
+package tigertonic
+
+import (
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHashedCredentials(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if nil != err {
+		t.Fatal(err)
+	}
+	salt := []byte("0123456789abcdef")
+	credentials := HashedCredentials{
+		"apr1": "$apr1$r31.....$HqJZimcKQFAMYayBlzkrA/",
+		"argon2id": fmt.Sprintf(
+			"$argon2id$v=19$m=1024,t=1,p=1$%s$%s",
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(argon2.IDKey([]byte("password"), salt, 1, 1024, 1, 32)),
+		),
+		"bcrypt":    string(bcryptHash),
+		"plaintext": "password",
+		"sha":       "{SHA}C+7Hteo/D9vJXQ3UfzxbwnXaijM=",
+	}
+	for username, password := range map[string]string{
+		"apr1":     "myPassword",
+		"argon2id": "password",
+		"bcrypt":   "password",
+		"sha":      "foo",
+	} {
+		if err := credentials.Verify(username, password); nil != err {
+			t.Fatal(username, err)
+		}
+		if err := credentials.Verify(username, "wrong-password"); nil == err {
+			t.Fatal(username)
+		}
+	}
+	if err := credentials.Verify("plaintext", "password"); nil == err {
+		t.Fatal("plaintext")
+	}
+	for _, password := range []string{"password", "myPassword", "foo"} {
+		if err := credentials.Verify("unknown", password); nil == err {
+			t.Fatal("unknown", password)
+		}
+	}
+}
+
+func TestHTTPBasicAuthHashed(t *testing.T) {
+	h := HTTPBasicAuthHashed(
+		HashedCredentials{"username": "{SHA}C+7Hteo/D9vJXQ3UfzxbwnXaijM="},
+		"Tiger Tonic",
+		NotFoundHandler{},
+	)
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/", nil)
+	r.SetBasicAuth("username", "foo")
+	h.ServeHTTP(w, r)
+	if http.StatusNotFound != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	w = &testResponseWriter{}
+	r.SetBasicAuth("username", "bar")
+	h.ServeHTTP(w, r)
+	if http.StatusUnauthorized != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+}
+
+func TestConfigureHtpasswd(t *testing.T) {
+	var credentials HashedCredentials
+	if err := Configure("config_test.htpasswd", &credentials); nil != err {
+		t.Fatal(err)
+	}
+	if 2 != len(credentials) {
+		t.Fatal(credentials)
+	}
+	if err := credentials.Verify("rcrowley", "myPassword"); nil != err {
+		t.Fatal(err)
+	}
+	if err := credentials.Verify("sha", "foo"); nil != err {
+		t.Fatal(err)
+	}
+	if err := Configure("config_test.htpasswd", &testConfig{}); nil == err {
+		t.Fatal("read an htpasswd file into a testConfig")
+	}
+}
+
+func TestLockout(t *testing.T) {
+	credentials := HashedCredentials{"username": "{SHA}C+7Hteo/D9vJXQ3UfzxbwnXaijM="}
+	f := NewLockout(2, 10*time.Millisecond).Func(credentials.Verify)
+	if err := f("username", "wrong-password"); nil == err {
+		t.Fatal("wrong-password")
+	}
+	if err := f("username", "foo"); nil != err {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := f("username", "wrong-password"); nil == err {
+			t.Fatal("wrong-password")
+		}
+	}
+	if err := f("username", "foo"); nil == err || "too many failed attempts" != err.Error() {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := f("username", "foo"); nil != err {
+		t.Fatal(err)
+	}
+}
+
+func TestLockoutBounded(t *testing.T) {
+	credentials := HashedCredentials{"username": "{SHA}C+7Hteo/D9vJXQ3UfzxbwnXaijM="}
+	l := NewLockout(1, time.Hour)
+	l.max = 3
+	f := l.Func(credentials.Verify)
+	f("username", "wrong-password")
+	for i := 0; i < 10; i++ {
+		f(fmt.Sprintf("guess%d", i), "wrong-password")
+		if len(l.failures) > l.max {
+			t.Fatal(len(l.failures))
+		}
+	}
+	if err := f("guess9", "foo"); nil == err || "too many failed attempts" != err.Error() {
+		t.Fatal(err)
+	}
+}
+
+func TestArgon2Parameters(t *testing.T) {
+	salt := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	key := base64.RawStdEncoding.EncodeToString(make([]byte, 32))
+	for _, params := range []string{
+		"m=1024,t=1,p=0",
+		"m=1024,t=0,p=1",
+		"m=4294967295,t=1,p=1",
+		"m=1024,t=4294967295,p=1",
+		"m=4,t=1,p=1",
+	} {
+		hash := fmt.Sprintf("$argon2id$v=19$%s$%s$%s", params, salt, key)
+		if _, err := parseArgon2(hash); nil == err {
+			t.Fatal(params)
+		}
+		if err := (HashedCredentials{"username": hash}).Verify("username", "password"); nil == err {
+			t.Fatal(params)
+		}
+	}
+	if _, err := parseArgon2(fmt.Sprintf("$argon2id$v=19$m=1024,t=1,p=1$%s$", salt)); nil == err {
+		t.Fatal("empty key")
+	}
+}
@@ -0,0 +1,187 @@
+package tigertonic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HealthChecks is a registry of named checks that report on the health of
+// a service and the things it depends on, in the spirit of Dropwizard's.
+// Its Healthz and Readyz methods return http.Handlers that run the checks
+// concurrently and respond 200 if they all pass and 503 otherwise.
+type HealthChecks struct {
+	checks   map[string]healthCheck
+	draining int32
+	mu       sync.RWMutex
+}
+
+type healthCheck struct {
+	f         func(context.Context) error
+	readiness bool
+	timeout   time.Duration
+}
+
+// HealthCheckResult is the outcome of one check.
+type HealthCheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// NewHealthChecks makes a new, empty HealthChecks.
+func NewHealthChecks() *HealthChecks {
+	return &HealthChecks{checks: make(map[string]healthCheck)}
+}
+
+// Register adds a check run by both Healthz and Readyz.  The check fails if
+// it returns an error, panics, or hasn't returned after the timeout, which
+// may be zero to wait as long as the request does.
+func (hc *HealthChecks) Register(name string, timeout time.Duration, f func(context.Context) error) {
+	hc.register(name, healthCheck{f, false, timeout})
+}
+
+// RegisterReadiness adds a check run only by Readyz, for dependencies a
+// service can't serve requests without but that restarting it won't fix.
+func (hc *HealthChecks) RegisterReadiness(name string, timeout time.Duration, f func(context.Context) error) {
+	hc.register(name, healthCheck{f, true, timeout})
+}
+
+func (hc *HealthChecks) register(name string, check healthCheck) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if _, ok := hc.checks[name]; ok {
+		panic(fmt.Sprintf("tigertonic: health check %s already registered", name))
+	}
+	hc.checks[name] = check
+}
+
+// Drain makes Readyz fail from now on so load balancers stop sending new
+// requests.  Healthz is unaffected.
+func (hc *HealthChecks) Drain() {
+	atomic.StoreInt32(&hc.draining, 1)
+}
+
+// Watch calls Drain as soon as the given Server begins to shut down and then
+// waits for the delay before the Server stops accepting connections, giving
+// load balancers time to see Readyz fail and send new requests elsewhere.
+// The delay should be at least the load balancers' health check interval.
+func (hc *HealthChecks) Watch(s *Server, delay time.Duration) {
+	s.RegisterPreShutdown(func() {
+		hc.Drain()
+		time.Sleep(delay)
+	})
+}
+
+// Healthz returns an http.Handler that runs every check registered with
+// Register.
+func (hc *HealthChecks) Healthz() http.Handler {
+	return &healthHandler{hc, false}
+}
+
+// Readyz returns an http.Handler that runs every check and fails without
+// running any once Drain has been called.
+func (hc *HealthChecks) Readyz() http.Handler {
+	return &healthHandler{hc, true}
+}
+
+// Run runs the checks concurrently and returns their results by name.
+// Readiness checks are only run if readiness is true.
+func (hc *HealthChecks) Run(ctx context.Context, readiness bool) map[string]HealthCheckResult {
+	hc.mu.RLock()
+	checks := make(map[string]healthCheck, len(hc.checks))
+	for name, check := range hc.checks {
+		if readiness || !check.readiness {
+			checks[name] = check
+		}
+	}
+	hc.mu.RUnlock()
+	var (
+		mu      sync.Mutex
+		results = make(map[string]HealthCheckResult, len(checks))
+		wg      sync.WaitGroup
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check healthCheck) {
+			defer wg.Done()
+			start := time.Now()
+			result := HealthCheckResult{Status: "ok"}
+			if err := check.run(ctx); nil != err {
+				result.Status, result.Error = "failing", err.Error()
+			}
+			result.Duration = time.Since(start).String()
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	return results
+}
+
+// run runs the check, giving up on it when its timeout passes even if it
+// doesn't respect its context.Context.
+func (check healthCheck) run(ctx context.Context) error {
+	if 0 != check.timeout {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, check.timeout)
+		defer cancel()
+	}
+	ch := make(chan error, 1)
+	go func() {
+		defer func() {
+			if err := recover(); nil != err {
+				ch <- fmt.Errorf("panic: %v", err)
+			}
+		}()
+		ch <- check.f(ctx)
+	}()
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		if context.DeadlineExceeded == ctx.Err() {
+			return errHealthCheckTimeout
+		}
+		return ctx.Err()
+	}
+}
+
+var errHealthCheckTimeout = errors.New("timed out")
+
+type healthHandler struct {
+	hc        *HealthChecks
+	readiness bool
+}
+
+// ServeHTTP responds 200 with the results of every check if they all pass
+// and 503 otherwise.
+func (h *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		Status string                       `json:"status"`
+		Checks map[string]HealthCheckResult `json:"checks,omitempty"`
+	}{Status: "ok"}
+	code := http.StatusOK
+	if h.readiness && 1 == atomic.LoadInt32(&h.hc.draining) {
+		body.Status, code = "draining", http.StatusServiceUnavailable
+	} else {
+		body.Checks = h.hc.Run(r.Context(), h.readiness)
+		for _, result := range body.Checks {
+			if "ok" != result.Status {
+				body.Status, code = "failing", http.StatusServiceUnavailable
+			}
+		}
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if "HEAD" != r.Method {
+		json.NewEncoder(w).Encode(body)
+	}
+}
@@ -0,0 +1,119 @@
+package tigertonic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHealthz(t *testing.T) {
+	hc := NewHealthChecks()
+	hc.Register("ok", 0, func(context.Context) error { return nil })
+	hc.RegisterReadiness("db", 0, func(context.Context) error { return errors.New("no database") })
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/healthz", nil)
+	hc.Healthz().ServeHTTP(w, r)
+	if http.StatusOK != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "application/json" != w.Header().Get("Content-Type") {
+		t.Fatal(w.Header().Get("Content-Type"))
+	}
+	var body struct {
+		Status string
+		Checks map[string]HealthCheckResult
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); nil != err {
+		t.Fatal(err)
+	}
+	if "ok" != body.Status || 1 != len(body.Checks) || "ok" != body.Checks["ok"].Status {
+		t.Fatal(body)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	hc := NewHealthChecks()
+	hc.Register("ok", 0, func(context.Context) error { return nil })
+	hc.RegisterReadiness("db", 0, func(context.Context) error { return errors.New("no database") })
+	hc.RegisterReadiness("slow", time.Millisecond, func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	hc.RegisterReadiness("panic", 0, func(context.Context) error { panic("at the disco") })
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/readyz", nil)
+	hc.Readyz().ServeHTTP(w, r)
+	if http.StatusServiceUnavailable != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	var body struct {
+		Status string
+		Checks map[string]HealthCheckResult
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); nil != err {
+		t.Fatal(err)
+	}
+	if "failing" != body.Status || 4 != len(body.Checks) {
+		t.Fatal(body)
+	}
+	if "no database" != body.Checks["db"].Error || "timed out" != body.Checks["slow"].Error || "panic: at the disco" != body.Checks["panic"].Error {
+		t.Fatal(body.Checks)
+	}
+}
+
+func TestReadyzDraining(t *testing.T) {
+	hc := NewHealthChecks()
+	s := NewServer("127.0.0.1:0", hc.Readyz())
+	hc.Watch(s, 0)
+	s.Close()
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/readyz", nil)
+	hc.Readyz().ServeHTTP(w, r)
+	if http.StatusServiceUnavailable != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "{\"status\":\"draining\"}\n" != w.Body.String() {
+		t.Fatal(w.Body.String())
+	}
+	w = &testResponseWriter{}
+	hc.Healthz().ServeHTTP(w, r)
+	if http.StatusOK != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+}
+
+func TestWatchDrainDelay(t *testing.T) {
+	hc := NewHealthChecks()
+	s := NewServer("127.0.0.1:0", hc.Readyz())
+	hc.Watch(s, 100*time.Millisecond)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	closed := make(chan struct{})
+	go func() {
+		s.Close()
+		close(closed)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// Still accepting connections but telling load balancers to go away.
+	resp, err := http.Get("http://" + l.Addr().String() + "/readyz")
+	if nil != err {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if http.StatusServiceUnavailable != resp.StatusCode {
+		t.Fatal(resp.StatusCode)
+	}
+
+	<-closed
+	if _, err := http.Get("http://" + l.Addr().String() + "/readyz"); nil == err {
+		t.Fatal("still accepting connections")
+	}
+}
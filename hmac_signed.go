@@ -0,0 +1,202 @@
+package tigertonic
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers that carry HMAC request signatures.
+const (
+	HMACKeyHeader       = "X-Signature-Key"
+	HMACNonceHeader     = "X-Signature-Nonce"
+	HMACSignatureHeader = "X-Signature"
+	HMACTimestampHeader = "X-Signature-Timestamp"
+)
+
+// HMACSecretStore finds the secret for a key ID.
+type HMACSecretStore interface {
+	HMACSecret(keyID string) ([]byte, bool)
+}
+
+// HMACSecrets is an HMACSecretStore that maps key IDs to secrets.
+type HMACSecrets map[string][]byte
+
+// HMACSecret returns the secret for the given key ID.
+func (secrets HMACSecrets) HMACSecret(keyID string) ([]byte, bool) {
+	secret, ok := secrets[keyID]
+	return secret, ok
+}
+
+// HMACVerifier checks HMAC-SHA256 request signatures made by HMACSigner
+// and remembers nonces to refuse replayed requests.
+type HMACVerifier struct {
+
+	// MaxBodySize is the most request body that's read to verify a
+	// signature.  Larger requests are refused with 413.
+	MaxBodySize int64
+
+	// MaxSkew is how far a request's timestamp may be from now.
+	MaxSkew time.Duration
+
+	mu      sync.Mutex
+	nonces  map[string]time.Time
+	secrets HMACSecretStore
+	swept   time.Time
+}
+
+// NewHMACVerifier makes an HMACVerifier that finds secrets in the given
+// HMACSecretStore, reads request bodies up to 1 MB, and allows five minutes
+// of clock skew.
+func NewHMACVerifier(secrets HMACSecretStore) *HMACVerifier {
+	return &HMACVerifier{
+		MaxBodySize: 1 << 20,
+		MaxSkew:     5 * time.Minute,
+		nonces:      make(map[string]time.Time),
+		secrets:     secrets,
+		swept:       time.Now(),
+	}
+}
+
+// HMACSigned returns an http.Handler that conditionally calls another
+// http.Handler if the request is signed by a key the HMACVerifier knows.
+// The request body is read to check the signature and restored for the
+// other http.Handler.  Requests without a valid signature get 401
+// Unauthorized and validly signed requests that are stale or replayed get
+// 403 Forbidden.
+func HMACSigned(verifier *HMACVerifier, h http.Handler) FirstHandler {
+	header := http.Header{"WWW-Authenticate": []string{"HMAC-SHA256"}}
+	return If(func(r *http.Request) (http.Header, error) {
+		if err := verifier.Verify(r); nil != err {
+			if _, ok := err.(Unauthorized); ok {
+				return header, err
+			}
+			return nil, err
+		}
+		return nil, nil
+	}, h)
+}
+
+// Verify checks the request's signature, timestamp, and nonce and returns
+// an HTTPEquivError if they aren't acceptable.  The request body is read and
+// replaced with a copy.
+func (v *HMACVerifier) Verify(r *http.Request) error {
+	keyID := r.Header.Get(HMACKeyHeader)
+	nonce := r.Header.Get(HMACNonceHeader)
+	timestamp := r.Header.Get(HMACTimestampHeader)
+	signature, err := hex.DecodeString(r.Header.Get(HMACSignatureHeader))
+	if "" == keyID || "" == nonce || "" == timestamp || 0 == len(signature) || nil != err {
+		return Unauthorized{errors.New("no HMAC signature specified")}
+	}
+	secret, ok := v.secrets.HMACSecret(keyID)
+	if !ok {
+		return Unauthorized{fmt.Errorf("unknown HMAC key %s", keyID)}
+	}
+	body, err := hmacBody(r, v.MaxBodySize)
+	if nil != err {
+		return err
+	}
+	if !hmac.Equal(signature, hmacSignature(secret, r, timestamp, nonce, body)) {
+		return Unauthorized{errors.New("invalid HMAC signature")}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if nil != err {
+		return Forbidden{fmt.Errorf("malformed timestamp %s", timestamp)}
+	}
+	now, t := time.Now(), time.Unix(seconds, 0)
+	if t.Before(now.Add(-v.MaxSkew)) || t.After(now.Add(v.MaxSkew)) {
+		return Forbidden{errors.New("stale HMAC signature")}
+	}
+	if !v.remember(keyID+":"+nonce, now) {
+		return Forbidden{errors.New("replayed HMAC nonce")}
+	}
+	return nil
+}
+
+// remember records a nonce and reports whether it's new.  Nonces are
+// forgotten once the timestamps they could have been sent with are stale.
+func (v *HMACVerifier) remember(nonce string, now time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if now.Sub(v.swept) > v.MaxSkew {
+		for n, t := range v.nonces {
+			if now.Sub(t) > 2*v.MaxSkew {
+				delete(v.nonces, n)
+			}
+		}
+		v.swept = now
+	}
+	if t, ok := v.nonces[nonce]; ok && now.Sub(t) <= 2*v.MaxSkew {
+		return false
+	}
+	v.nonces[nonce] = now
+	return true
+}
+
+// HMACSigner signs requests for an HMACVerifier to check, as a client of a
+// service that uses HMACSigned would.
+type HMACSigner struct {
+	KeyID  string
+	Secret []byte
+}
+
+// Sign adds a timestamp, a random nonce, and a signature over them, the
+// method, the request URI, and the body to the request's headers.  The
+// request body is read and replaced with a copy.
+func (s *HMACSigner) Sign(r *http.Request) error {
+	body, err := hmacBody(r, -1)
+	if nil != err {
+		return err
+	}
+	nonce := RandomBase62String(16)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	r.Header.Set(HMACKeyHeader, s.KeyID)
+	r.Header.Set(HMACNonceHeader, nonce)
+	r.Header.Set(HMACTimestampHeader, timestamp)
+	r.Header.Set(HMACSignatureHeader, hex.EncodeToString(hmacSignature(s.Secret, r, timestamp, nonce, body)))
+	return nil
+}
+
+// hmacBody reads the request body, up to the given size unless it's
+// negative, and replaces it with a copy.
+func hmacBody(r *http.Request, maxSize int64) ([]byte, error) {
+	if nil == r.Body {
+		return nil, nil
+	}
+	reader := io.Reader(r.Body)
+	if 0 <= maxSize {
+		reader = io.LimitReader(r.Body, maxSize+1)
+	}
+	body, err := ioutil.ReadAll(reader)
+	r.Body.Close()
+	if nil != err {
+		return nil, BadRequest{err}
+	}
+	if 0 <= maxSize && int64(len(body)) > maxSize {
+		return nil, RequestEntityTooLarge{errors.New("request body too large to verify")}
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func hmacSignature(secret []byte, r *http.Request, timestamp, nonce string, body []byte) []byte {
+	// Servers sign the request URI as received since TrieServeMux rewrites
+	// the query string with URL parameters.
+	uri := r.RequestURI
+	if "" == uri {
+		uri = r.URL.RequestURI()
+	}
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n", r.Method, uri, timestamp, nonce)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
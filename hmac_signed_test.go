@@ -0,0 +1,115 @@
+package tigertonic
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHMACSigned(t *testing.T) {
+	var body string
+	h := testHMACSigned(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, _ := ioutil.ReadAll(r.Body)
+		body = string(buf)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	r := testHMACRequest(t, "key", "secret", `{"foo":"bar"}`)
+	w := &testResponseWriter{}
+	h.ServeHTTP(w, r)
+	if http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+	if `{"foo":"bar"}` != body {
+		t.Fatal(body)
+	}
+}
+
+func TestHMACSignedUnsigned(t *testing.T) {
+	r, _ := http.NewRequest("POST", "http://example.com/foo", strings.NewReader("foo"))
+	w := &testResponseWriter{}
+	testHMACSigned(NotFoundHandler{}).ServeHTTP(w, r)
+	if http.StatusUnauthorized != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "HMAC-SHA256" != w.Header().Get("WWW-Authenticate") {
+		t.Fatal(w.Header())
+	}
+}
+
+func TestHMACSignedInvalid(t *testing.T) {
+	h := testHMACSigned(NotFoundHandler{})
+	for description, r := range map[string]*http.Request{
+		"unknown key":   testHMACRequest(t, "other", "secret", "foo"),
+		"wrong secret":  testHMACRequest(t, "key", "wrong", "foo"),
+		"tampered body": testHMACRequest(t, "key", "secret", "foo"),
+	} {
+		if "tampered body" == description {
+			r.Body = ioutil.NopCloser(strings.NewReader("bar"))
+		}
+		w := &testResponseWriter{}
+		h.ServeHTTP(w, r)
+		if http.StatusUnauthorized != w.StatusCode {
+			t.Fatal(description, w.StatusCode)
+		}
+	}
+}
+
+func TestHMACSignedStale(t *testing.T) {
+	r := testHMACRequest(t, "key", "secret", "foo")
+	timestamp := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	r.Header.Set(HMACTimestampHeader, timestamp)
+	r.Header.Set(HMACSignatureHeader, hex.EncodeToString(hmacSignature(
+		[]byte("secret"),
+		r,
+		timestamp,
+		r.Header.Get(HMACNonceHeader),
+		[]byte("foo"),
+	)))
+	w := &testResponseWriter{}
+	testHMACSigned(NotFoundHandler{}).ServeHTTP(w, r)
+	if http.StatusForbidden != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+}
+
+func TestHMACSignedReplayed(t *testing.T) {
+	h := testHMACSigned(NotFoundHandler{})
+	r := testHMACRequest(t, "key", "secret", "foo")
+	w := &testResponseWriter{}
+	h.ServeHTTP(w, r)
+	if http.StatusNotFound != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	r.Body = ioutil.NopCloser(strings.NewReader("foo"))
+	w = &testResponseWriter{}
+	h.ServeHTTP(w, r)
+	if http.StatusForbidden != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+}
+
+func TestHMACSignedTooLarge(t *testing.T) {
+	v := NewHMACVerifier(HMACSecrets{"key": []byte("secret")})
+	v.MaxBodySize = 2
+	w := &testResponseWriter{}
+	HMACSigned(v, NotFoundHandler{}).ServeHTTP(w, testHMACRequest(t, "key", "secret", "foo"))
+	if http.StatusRequestEntityTooLarge != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+}
+
+func testHMACRequest(t *testing.T, keyID, secret, body string) *http.Request {
+	r, _ := http.NewRequest("POST", "http://example.com/foo?bar=baz", strings.NewReader(body))
+	if err := (&HMACSigner{KeyID: keyID, Secret: []byte(secret)}).Sign(r); nil != err {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func testHMACSigned(h http.Handler) FirstHandler {
+	return HMACSigned(NewHMACVerifier(HMACSecrets{"key": []byte("secret")}), h)
+}
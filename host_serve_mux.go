@@ -3,6 +3,7 @@ package tigertonic
 import (
 	"log"
 	"net/http"
+	"sort"
 	"strings"
 )
 
@@ -47,6 +48,30 @@ func (mux HostServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	handler.ServeHTTP(w, r)
 }
 
+// Routes returns every http.Handler registered in the map, ordered by
+// hostname, recursing into TrieServeMuxes and HostServeMuxes even if they're
+// wrapped in other Tiger Tonic middleware.
+func (mux HostServeMux) Routes() []Route {
+	return mux.routes("")
+}
+
+func (mux HostServeMux) routes(namespace string) []Route {
+	hostnames := make([]string, 0, len(mux))
+	for hostname := range mux {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+	var routes []Route
+	for _, hostname := range hostnames {
+		if nested, ok := nestedRoutes(mux[hostname], namespace, hostname); ok {
+			routes = append(routes, nested...)
+		} else {
+			routes = append(routes, Route{hostname, "", namespace, namespace, mux[hostname], nil, nil})
+		}
+	}
+	return routes
+}
+
 // Given that we know that the port in the URL was correct, otherwise we
 // wouldn't be hitting the service, we can strip the port and use it to locate
 // the hostname in the hostname map.
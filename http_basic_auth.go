@@ -2,6 +2,8 @@ package tigertonic
 
 import (
 	"bytes"
+	"context"
+	"crypto/subtle"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -10,8 +12,10 @@ import (
 
 // HTTPBasicAuth returns an http.Handler that conditionally calls another
 // http.Handler if the request includes an Authorization header with a
-// username and password that appear in the map of credentials.  Otherwise,
-// respond 401 Unauthorized.
+// username and password that appear in the map of credentials.  The username
+// is added to the request's context.Context, where
+// HTTPBasicAuthUsernameFromContext can find it.  Otherwise, respond 401
+// Unauthorized.
 func HTTPBasicAuth(
 	credentials map[string]string,
 	realm string,
@@ -19,7 +23,8 @@ func HTTPBasicAuth(
 ) FirstHandler {
 	return HTTPBasicAuthFunc(
 		func(username, password string) error {
-			if p, ok := credentials[username]; !ok || p != password {
+			p, ok := credentials[username]
+			if 1 != subtle.ConstantTimeCompare([]byte(p), []byte(password)) || !ok {
 				return errors.New("unauthorized")
 			}
 			return nil
@@ -32,7 +37,9 @@ func HTTPBasicAuth(
 // HTTPBasicAuthFunc returns an http.Handler that conditionally calls another
 // http.Handler if the request includes an Authorization header with a
 // username and password that produce a nil error when passed to the given
-// function.  Otherwise, respond 401 Unauthorized.
+// function.  The username is added to the request's context.Context, where
+// HTTPBasicAuthUsernameFromContext can find it.  Otherwise, respond 401
+// Unauthorized.
 func HTTPBasicAuthFunc(
 	f func(string, string) error,
 	realm string,
@@ -41,18 +48,28 @@ func HTTPBasicAuthFunc(
 	header := http.Header{
 		"WWW-Authenticate": []string{fmt.Sprintf("Basic realm=\"%s\"", realm)},
 	}
-	return If(func(r *http.Request) (http.Header, error) {
+	return IfContext(func(r *http.Request) (context.Context, http.Header, error) {
 		username, password, err := httpBasicAuth(r.Header)
 		if nil != err {
-			return header, err
+			return nil, header, err
 		}
 		if err := f(username, password); nil != err {
-			return header, Unauthorized{err}
+			return nil, header, Unauthorized{err}
 		}
-		return nil, nil
+		return context.WithValue(r.Context(), httpBasicAuthUsernameKey{}, username), nil, nil
 	}, h)
 }
 
+// HTTPBasicAuthUsernameFromContext returns the username verified by
+// HTTPBasicAuth or HTTPBasicAuthFunc, as passed to Marshaled functions that
+// take a context.Context, or the empty string if there isn't one.
+func HTTPBasicAuthUsernameFromContext(ctx context.Context) string {
+	username, _ := ctx.Value(httpBasicAuthUsernameKey{}).(string)
+	return username
+}
+
+type httpBasicAuthUsernameKey struct{}
+
 func httpBasicAuth(h http.Header) (username, password string, err error) {
 	authorization := h.Get("Authorization")
 	if 6 > len(authorization) || "Basic " != authorization[:6] {
@@ -10,14 +10,21 @@ func TestHTTPBasicAuthAuthorized(t *testing.T) {
 	w := &testResponseWriter{}
 	r, _ := http.NewRequest("GET", "http://example.com/", nil)
 	r.SetBasicAuth("username", "password")
+	var username string
 	HTTPBasicAuth(
 		map[string]string{"username": "password"},
 		"Tiger Tonic",
-		NotFoundHandler{},
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username = HTTPBasicAuthUsernameFromContext(r.Context())
+			w.WriteHeader(http.StatusNotFound)
+		}),
 	).ServeHTTP(w, r)
 	if http.StatusNotFound != w.StatusCode {
 		t.Fatal(w.StatusCode)
 	}
+	if "username" != username {
+		t.Fatal(username)
+	}
 }
 
 func TestHTTPBasicAuthBase64Error(t *testing.T) {
@@ -298,6 +298,22 @@ func (err Teapot) Name() string { return errorName(err.Err, "") }
 
 func (err Teapot) StatusCode() int { return http.StatusTeapot }
 
+type UnprocessableEntity struct {
+	Err
+}
+
+func (err UnprocessableEntity) Name() string { return errorName(err.Err, "") }
+
+func (err UnprocessableEntity) StatusCode() int { return http.StatusUnprocessableEntity }
+
+type TooManyRequests struct {
+	Err
+}
+
+func (err TooManyRequests) Name() string { return errorName(err.Err, "") }
+
+func (err TooManyRequests) StatusCode() int { return http.StatusTooManyRequests }
+
 type InternalServerError struct {
 	Err
 }
@@ -41,6 +41,7 @@ func (jl *JSONLogger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	rURI := r.URL.RequestURI()
 	body := &jsonReadCloser{r.Body, bytes.Buffer{}}
 	requestID := jl.RequestIDCreator(r)
+	r = withRequestID(r, requestID)
 	r.Body = body
 	jl.handler.ServeHTTP(tee, r)
 	buf, err := json.Marshal(&jsonLog{
@@ -51,6 +52,7 @@ func (jl *JSONLogger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				Header: jsonLogHTTPHeader(r.Header),
 				Method: r.Method,
 				Path:   rURI,
+				Peer:   RequestPeerIdentity(r),
 			},
 			Response: jsonLogHTTPResponse{
 				Body:       tee.Body.String(),
@@ -126,6 +128,7 @@ type jsonLogHTTPRequest struct {
 	Header map[string]string `json:"headers"`
 	Method string            `json:"method"`
 	Path   string            `json:"url"`
+	Peer   *PeerIdentity     `json:"peer,omitempty"`
 }
 
 type jsonLogHTTPResponse struct {
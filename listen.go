@@ -0,0 +1,183 @@
+package tigertonic
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd and by
+// Server.Reexec, following sd_listen_fds(3).
+const listenFDsStart = 3
+
+var (
+	inherited     []net.Listener
+	inheritedErr  error
+	inheritedMu   sync.Mutex
+	inheritedOnce sync.Once
+)
+
+// InheritedListeners returns the listening sockets passed to this process
+// by systemd socket activation or by Server.Reexec in a parent process, as
+// described by the LISTEN_FDS and LISTEN_PID environment variables.  Those
+// variables are unset so the sockets aren't passed on again by accident.
+// Server.ListenAndServe adopts an inherited listener with a matching address
+// instead of creating a new one, so most programs needn't call this at all.
+func InheritedListeners() ([]net.Listener, error) {
+	inheritedOnce.Do(func() {
+		defer os.Unsetenv("LISTEN_FDS")
+		defer os.Unsetenv("LISTEN_FDNAMES")
+		defer os.Unsetenv("LISTEN_PID")
+		if pid := os.Getenv("LISTEN_PID"); "" != pid && strconv.Itoa(os.Getpid()) != pid {
+			return
+		}
+		n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if nil != err || n < 1 {
+			return
+		}
+		inherited, inheritedErr = listenersFromFDs(listenFDsStart, n)
+	})
+	inheritedMu.Lock()
+	defer inheritedMu.Unlock()
+	return append([]net.Listener(nil), inherited...), inheritedErr
+}
+
+// listenersFromFDs makes net.Listeners from n consecutive file descriptors.
+func listenersFromFDs(start, n int) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, n)
+	for fd := start; fd < start+n; fd++ {
+		f := os.NewFile(uintptr(fd), fmt.Sprintf("listener%d", fd))
+		l, err := net.FileListener(f)
+		f.Close()
+		if nil != err {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("inherited file descriptor %d: %s", fd, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// inheritedListener removes and returns the inherited listener bound to the
+// given address, if there is one.
+func inheritedListener(addr string) (net.Listener, error) {
+	if _, err := InheritedListeners(); nil != err {
+		return nil, err
+	}
+	inheritedMu.Lock()
+	defer inheritedMu.Unlock()
+	for i, l := range inherited {
+		if listenerAddrMatches(l.Addr(), addr) {
+			inherited = append(inherited[:i], inherited[i+1:]...)
+			return l, nil
+		}
+	}
+	return nil, nil
+}
+
+// listenerAddrMatches reports whether a listener bound to the given
+// net.Addr is what net.Listen("tcp", addr) would have created.
+func listenerAddrMatches(laddr net.Addr, addr string) bool {
+	tcpAddr, ok := laddr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if nil != err {
+		return false
+	}
+	if p, err := net.LookupPort("tcp", port); nil != err || p != tcpAddr.Port {
+		return false
+	}
+	if "" == host {
+		return tcpAddr.IP.IsUnspecified()
+	}
+	if ip := net.ParseIP(host); nil != ip {
+		return ip.Equal(tcpAddr.IP)
+	}
+
+	// A hostname may resolve to several addresses in an order that differs
+	// from the one the parent process saw so any of them will do.
+	ips, err := net.LookupIP(host)
+	if nil != err {
+		return false
+	}
+	for _, ip := range ips {
+		if ip.Equal(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reexec starts a new copy of this program with the same arguments and
+// passes it the Server's listening sockets, which it will adopt in
+// ListenAndServe.  Follow it with Close to drain this process while the new
+// one accepts connections.
+func (s *Server) Reexec() (*os.Process, error) {
+	s.mu.Lock()
+	var files []*os.File
+	for _, l := range s.listeners {
+		filer, ok := l.(interface {
+			File() (*os.File, error)
+		})
+		if !ok {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("%T listening on %s can't be passed to a child process", l, l.Addr())
+		}
+		f, err := filer.File()
+		if nil != err {
+			s.mu.Unlock()
+			return nil, err
+		}
+		defer f.Close()
+		files = append(files, f)
+	}
+	s.mu.Unlock()
+	path, err := os.Executable()
+	if nil != err {
+		return nil, err
+	}
+	cmd := exec.Command(path, os.Args[1:]...)
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, "LISTEN_") {
+			cmd.Env = append(cmd.Env, env)
+		}
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("LISTEN_FDS=%d", len(files)))
+	cmd.ExtraFiles = files
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Start(); nil != err {
+		return nil, err
+	}
+	return cmd.Process, nil
+}
+
+// ReexecOn calls Reexec and then Close when any of the given signals, for
+// example syscall.SIGUSR2, arrives.  If the new process can't be started,
+// this one keeps serving.
+func (s *Server) ReexecOn(sig ...os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig...)
+	go func() {
+		for range ch {
+			p, err := s.Reexec()
+			if nil != err {
+				log.Printf("reexec: %s\n", err)
+				continue
+			}
+			log.Printf("reexec: started process %d, stopping gracefully\n", p.Pid)
+			signal.Stop(ch)
+			s.Close()
+			return
+		}
+	}()
+}
@@ -0,0 +1,110 @@
+package tigertonic
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestListenersFromFDs(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	listeners, err := listenersFromFDs(int(f.Fd()), 1)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer listeners[0].Close()
+	if l.Addr().String() != listeners[0].Addr().String() {
+		t.Fatal(listeners[0].Addr())
+	}
+}
+
+func TestListenerAddrMatches(t *testing.T) {
+	for addr, matches := range map[string]bool{
+		"127.0.0.1:8000": true,
+		"127.0.0.1:8001": false,
+		":8000":          false,
+		"10.0.0.1:8000":  false,
+	} {
+		if matches != listenerAddrMatches(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8000}, addr) {
+			t.Fatal(addr, matches)
+		}
+	}
+	if !listenerAddrMatches(&net.TCPAddr{IP: net.IPv6unspecified, Port: 80}, ":http") {
+		t.Fatal(":http")
+	}
+}
+
+func TestListenerAddrMatchesHostname(t *testing.T) {
+	ips, err := net.LookupIP("localhost")
+	if nil != err || 0 == len(ips) {
+		t.Skip("localhost doesn't resolve")
+	}
+	for _, ip := range ips {
+		if !listenerAddrMatches(&net.TCPAddr{IP: ip, Port: 8000}, "localhost:8000") {
+			t.Fatal(ip, ips)
+		}
+	}
+	if listenerAddrMatches(&net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 8000}, "localhost:8000") {
+		t.Fatal(ips)
+	}
+}
+
+func TestListenerAddrMatchesLocalhost(t *testing.T) {
+	ips, err := net.LookupIP("localhost")
+	if nil != err {
+		t.Skip("localhost doesn't resolve")
+	}
+	ipv4 := false
+	for _, ip := range ips {
+		if ip.Equal(net.IPv4(127, 0, 0, 1)) {
+			ipv4 = true
+		}
+	}
+	if !ipv4 {
+		t.Skip("localhost doesn't resolve to 127.0.0.1")
+	}
+
+	// A listener on 127.0.0.1 matches localhost even when localhost
+	// resolves to ::1 first.
+	if !listenerAddrMatches(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8000}, "localhost:8000") {
+		t.Fatal(ips)
+	}
+}
+
+func TestServerInheritedListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	InheritedListeners()
+	inheritedMu.Lock()
+	inherited = append(inherited, l)
+	inheritedMu.Unlock()
+	s := NewServer(l.Addr().String(), http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	go s.ListenAndServe()
+	defer s.Close()
+	rs, err := http.Get(fmt.Sprintf("http://%s", l.Addr()))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if http.StatusNoContent != rs.StatusCode {
+		t.Fatal(rs.StatusCode)
+	}
+	inheritedMu.Lock()
+	defer inheritedMu.Unlock()
+	if 0 != len(inherited) {
+		t.Fatal(inherited)
+	}
+}
@@ -1,6 +1,7 @@
 package tigertonic
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -56,6 +57,9 @@ func (l *ApacheLogger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		userAgent = "-"
 	}
 	username, _, _ := httpBasicAuth(r.Header)
+	if id := RequestPeerIdentity(r); "" == username && nil != id {
+		username = strings.Replace(id.String(), " ", "_", -1)
+	}
 	if "" == username {
 		username = "-"
 	}
@@ -131,6 +135,7 @@ func (l *MultilineLogger) Println(v ...interface{}) {
 // output and pass through to the underlying http.Handler.
 func (l *MultilineLogger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	requestID := l.RequestIDCreator(r)
+	r = withRequestID(r, requestID)
 	l.Printf(
 		"%s > %s %s %s",
 		requestID,
@@ -169,11 +174,28 @@ type RequestID string
 // RequestID for it.
 type RequestIDCreator func(r *http.Request) RequestID
 
-// Default RequestIDCreator implementation
+// Default RequestIDCreator implementation, which reuses the RequestID of an
+// outer logger, if there is one.
 func requestIDCreator(r *http.Request) RequestID {
+	if requestID := RequestIDFromContext(r.Context()); "" != requestID {
+		return requestID
+	}
 	return NewRequestID()
 }
 
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the RequestID a logger gave the request with
+// the given context.Context or the empty string if it wasn't logged.
+func RequestIDFromContext(ctx context.Context) RequestID {
+	requestID, _ := ctx.Value(requestIDKey{}).(RequestID)
+	return requestID
+}
+
+func withRequestID(r *http.Request, requestID RequestID) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, requestID))
+}
+
 // NewRequestID returns a new 16-character random RequestID.
 func NewRequestID() RequestID {
 	return RequestID(RandomBase62Bytes(16))
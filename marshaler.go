@@ -1,7 +1,8 @@
 package tigertonic
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -10,40 +11,83 @@ import (
 	"strings"
 )
 
-// Marshaler is an http.Handler that unmarshals JSON input, handles the request
-// via a function, and marshals JSON output.  It refuses to answer requests
-// without an Accept header that includes the application/json content type.
+// Marshaler is an http.Handler that unmarshals input, handles the request
+// via a function, and marshals output using the Codecs registered for the
+// request's Content-Type and Accept headers.  It refuses to answer requests
+// with an Accept header that doesn't allow any registered media type.
 type Marshaler struct {
-	v reflect.Value
+	v      reflect.Value
+	ctx    bool
+	params reflect.Type
 }
 
 // Marshaled returns an http.Handler that implements its ServeHTTP method by
 // calling the given function, the signature of which must be
 //
-//     func(*url.URL, http.Header, *Request) (int, http.Header, *Response)
+//     func(*url.URL, http.Header, *Request) (int, http.Header, *Response, error)
 //
 // where Request and Response may be any struct type of your choosing.
+// Request bodies are checked by Validate before the function is called.
+//
+// The function may also take a pointer to a params struct immediately after
+// the http.Header, as in
+//
+//     func(*url.URL, http.Header, *Parameters, *Request) (int, http.Header, *Response, error)
+//
+// where Parameters is any struct type that embeds Params and has fields
+// tagged path:"name" or query:"name".  Those fields are set from the
+// wildcards in the URL pattern and the query string, respectively, and may
+// be strings, booleans, numbers, time.Durations, time.Times, or slices or
+// pointers of those.  Parameters that can't be converted are answered 400
+// Bad Request.
+//
+// The function may also take the request's context.Context as its first
+// argument, as in
+//
+//     func(context.Context, *url.URL, http.Header, *Request) (int, http.Header, *Response, error)
+//
+// so it can give up when the client goes away or a deadline set by some
+// middleware passes.  ContextValue retrieves the per-request context object
+// from WithContext.
 func Marshaled(i interface{}) *Marshaler {
 	t := reflect.TypeOf(i)
 	if reflect.Func != t.Kind() {
 		panic(NewMarshalerError("kind was %v, not Func", t.Kind()))
 	}
-	if 2 != t.NumIn() && 3 != t.NumIn() && 4 != t.NumIn() {
+	var offset int
+	if 0 < t.NumIn() && contextType == t.In(0) {
+		offset = 1
+	}
+	var params reflect.Type
+	if 2+offset < t.NumIn() && isParamsType(t.In(2+offset)) {
+		params = t.In(2 + offset)
+		if err := checkParamsType(params); nil != err {
+			panic(NewMarshalerError("%s", err))
+		}
+		if t.NumIn() < 3+offset || 5+offset < t.NumIn() {
+			panic(NewMarshalerError(
+				"input arity was %v, not %v, %v, or %v",
+				t.NumIn(),
+				3+offset, 4+offset, 5+offset,
+			))
+		}
+	} else if t.NumIn() < 2+offset || 4+offset < t.NumIn() {
 		panic(NewMarshalerError(
-			"input arity was %v, not 2, 3, or 4",
+			"input arity was %v, not %v, %v, or %v",
 			t.NumIn(),
+			2+offset, 3+offset, 4+offset,
 		))
 	}
-	if "*url.URL" != t.In(0).String() {
+	if "*url.URL" != t.In(offset).String() {
 		panic(NewMarshalerError(
 			"type of first argument was %v, not *url.URL",
-			t.In(0),
+			t.In(offset),
 		))
 	}
-	if "http.Header" != t.In(1).String() {
+	if "http.Header" != t.In(1+offset).String() {
 		panic(NewMarshalerError(
 			"type of second argument was %v, not http.Header",
-			t.In(1),
+			t.In(1+offset),
 		))
 	}
 	if 4 != t.NumOut() {
@@ -73,14 +117,19 @@ func Marshaled(i interface{}) *Marshaler {
 			t.Out(3),
 		))
 	}
-	return &Marshaler{reflect.ValueOf(i)}
+	for i := 2 + offset; i < t.NumIn(); i++ {
+		if err := checkValidateTags(t.In(i), make(map[reflect.Type]bool)); nil != err {
+			panic(NewMarshalerError("%s", err))
+		}
+	}
+	return &Marshaler{reflect.ValueOf(i), 1 == offset, params}
 }
 
-// ServeHTTP unmarshals JSON input, handles the request via the function, and
-// marshals JSON output.
+// ServeHTTP unmarshals input, handles the request via the function, and
+// marshals output, JSON by default or any other media type in Codecs.
 // If the output of the handler function implements io.Reader the headers must
 // contain a 'Content-Type'; the stream will be written directly to the
-// requestor without being marshaled to JSON.
+// requestor without being marshaled.
 // Additionally if the output implements the io.Closer the stream will be
 // automatically closed after flushing.
 func (m *Marshaler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -94,16 +143,41 @@ func (m *Marshaler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			isCloser = out2.Implements(reflect.TypeOf((*io.Closer)(nil)).Elem())
 		}
 	}
-	if !isReader && !acceptJSON(r) {
-		ResponseErrorWriter.WritePlaintextError(w, NewHTTPEquivError(NewMarshalerError(
-			"Accept header %q does not allow \"application/json\"",
-			r.Header.Get("Accept"),
-		), http.StatusNotAcceptable))
-		return
+	var (
+		mediaType string
+		codec     Codec
+	)
+	if !isReader {
+		var ok bool
+		if mediaType, codec, ok = responseCodec(r); !ok {
+			ResponseErrorWriter.WritePlaintextError(w, NewHTTPEquivError(NewMarshalerError(
+				"Accept header %q does not allow any of %s",
+				r.Header.Get("Accept"),
+				strings.Join(codecTypes, ", "),
+			), http.StatusNotAcceptable))
+			return
+		}
+	}
+	var in []reflect.Value
+	if m.ctx {
+		in = append(in, reflect.ValueOf(r.Context()))
+	}
+	in = append(in, reflect.ValueOf(r.URL), reflect.ValueOf(r.Header))
+	if nil != m.params {
+		params, err := bindParams(m.params, r)
+		if nil != err {
+			ResponseErrorWriter.WriteError(r, w, BadRequest{err})
+			return
+		}
+		if err := Validate(params.Interface()); nil != err {
+			ResponseErrorWriter.WriteError(r, w, err)
+			return
+		}
+		in = append(in, params)
 	}
 	var rq reflect.Value
-	if 2 < m.v.Type().NumIn() {
-		in2 := m.v.Type().In(2)
+	if len(in) < m.v.Type().NumIn() {
+		in2 := m.v.Type().In(len(in))
 		if reflect.Interface == in2.Kind() && 0 == in2.NumMethod() {
 			rq = nilRequest
 		} else if reflect.Slice == in2.Kind() || reflect.Map == in2.Kind() {
@@ -125,26 +199,27 @@ func (m *Marshaler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			))
 			return
 		}
-		if !strings.HasPrefix(
-			r.Header.Get("Content-Type"),
-			"application/json",
-		) {
+		decoder, ok := requestCodec(r)
+		if !ok {
 			ResponseErrorWriter.WriteError(r, w, NewHTTPEquivError(NewMarshalerError(
-				"Content-Type header is %s, not application/json",
+				"Content-Type header is %s, not one of %s",
 				r.Header.Get("Content-Type"),
+				strings.Join(codecTypes, ", "),
 			), http.StatusUnsupportedMediaType))
 			return
 		}
-		decoder := reflect.ValueOf(json.NewDecoder(r.Body))
-		out := decoder.MethodByName("Decode").Call([]reflect.Value{rq})
-		if !out[0].IsNil() {
+		if err := decoder.Decode(r.Body, rq.Interface()); nil != err {
 			ResponseErrorWriter.WriteError(r, w, NewHTTPEquivError(
-				out[0].Interface().(error),
+				err,
 				http.StatusBadRequest,
 			))
 			return
 		}
 		r.Body.Close()
+		if err := Validate(rq.Interface()); nil != err {
+			ResponseErrorWriter.WriteError(r, w, err)
+			return
+		}
 	} else if nilRequest != rq {
 		log.Printf(
 			"%s request body isn't an empty interface; this is weird and is being ignored\n",
@@ -154,29 +229,13 @@ func (m *Marshaler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if reflect.Slice == rq.Elem().Kind() || reflect.Map == rq.Elem().Kind() {
 		rq = rq.Elem()
 	}
-	var out []reflect.Value
-	switch m.v.Type().NumIn() {
-	case 2:
-		out = m.v.Call([]reflect.Value{
-			reflect.ValueOf(r.URL),
-			reflect.ValueOf(r.Header),
-		})
-	case 3:
-		out = m.v.Call([]reflect.Value{
-			reflect.ValueOf(r.URL),
-			reflect.ValueOf(r.Header),
-			rq,
-		})
-	case 4:
-		out = m.v.Call([]reflect.Value{
-			reflect.ValueOf(r.URL),
-			reflect.ValueOf(r.Header),
-			rq,
-			reflect.ValueOf(Context(r)),
-		})
-	default:
-		panic(m.v.Type())
+	if len(in) < m.v.Type().NumIn() {
+		in = append(in, rq)
 	}
+	if len(in) < m.v.Type().NumIn() {
+		in = append(in, reflect.ValueOf(Context(r)))
+	}
+	out := m.v.Call(in)
 	code := int(out[0].Int())
 	header := out[1].Interface().(http.Header)
 	rs := out[2].Interface()
@@ -213,10 +272,24 @@ func (m *Marshaler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	} else {
-		wHeader.Set("Content-Type", "application/json")
+		wHeader.Set("Content-Type", mediaType)
+	}
+	hasBody := nil != rs && http.StatusNoContent != code && (out[2].Kind() != reflect.Ptr || !out[2].IsNil())
+
+	// Encode the response before writing the status so a response that
+	// can't be encoded is answered 500 instead of with an empty body.
+	var buf bytes.Buffer
+	if hasBody && !isReader {
+		if err := codec.Encode(&buf, rs); nil != err {
+			ResponseErrorWriter.WriteError(r, w, NewHTTPEquivError(
+				err,
+				http.StatusInternalServerError,
+			))
+			return
+		}
 	}
 	w.WriteHeader(code)
-	if nil != rs && http.StatusNoContent != code && (out[2].Kind() != reflect.Ptr || !out[2].IsNil()) {
+	if hasBody {
 		if isReader {
 			reader := rs.(io.Reader)
 			_, err := io.Copy(w, reader)
@@ -229,7 +302,7 @@ func (m *Marshaler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 					log.Println(err)
 				}
 			}
-		} else if err := json.NewEncoder(w).Encode(rs); nil != err {
+		} else if _, err := buf.WriteTo(w); nil != err {
 			log.Println(err)
 		}
 	}
@@ -245,4 +318,7 @@ func NewMarshalerError(format string, args ...interface{}) MarshalerError {
 
 func (e MarshalerError) Error() string { return string(e) }
 
-var nilRequest = reflect.ValueOf((*interface{})(nil))
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	nilRequest  = reflect.ValueOf((*interface{})(nil))
+)
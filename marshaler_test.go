@@ -2,12 +2,14 @@ package tigertonic
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 	"net/url"
 	"testing"
+	"time"
 )
 
 func TestMarshaledCalm(t *testing.T) {
@@ -460,3 +462,36 @@ type testRequest struct {
 type testResponse struct {
 	Foo string `json:"foo"`
 }
+
+func TestMarshaledContext(t *testing.T) {
+	deadline := time.Now().Add(time.Minute)
+	handler := WithContext(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithDeadline(r.Context(), deadline)
+		defer cancel()
+		Marshaled(func(ctx context.Context, u *url.URL, h http.Header, rq *testRequest, c *testContext) (int, http.Header, *testResponse, error) {
+			if d, ok := ctx.Deadline(); !ok || !d.Equal(deadline) {
+				t.Error(d, ok)
+			}
+			if ContextValue(ctx) != c || "bar" != rq.Foo {
+				t.Error(ContextValue(ctx), c, rq)
+			}
+			return http.StatusOK, nil, &testResponse{"bar"}, nil
+		}).ServeHTTP(w, r.WithContext(ctx))
+	}), testContext{})
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("POST", "http://example.com/foo", bytes.NewBufferString(`{"foo":"bar"}`))
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(w, r)
+	if http.StatusOK != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+}
+
+func TestMarshaledPanicContextNumIn(t *testing.T) {
+	testMarshaledPanic(func(ctx context.Context, u *url.URL) {}, t)
+	testMarshaledPanic(func(ctx context.Context, u, h, rq, foo, bar interface{}) {}, t)
+	testMarshaledPanic(func(ctx context.Context, h http.Header, u *url.URL) (int, http.Header, *testResponse, error) {
+		return 0, nil, nil, nil
+	}, t)
+}
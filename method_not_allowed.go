@@ -28,8 +28,14 @@ func (h MethodNotAllowedHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 	w.Header().Set("Allow", strings.Join(methods, ", "))
 	if "OPTIONS" == r.Method {
 		if method := r.Header.Get(CORSRequestMethod); method != "" {
-			w.Header().Set(CORSAllowMethods, strings.Join(methods, ", "))
-			if cors, ok := h.mux.methods[method].(*CORSHandler); ok {
+			cors, _ := findHandler(h.mux.methods[method], func(h http.Handler) bool {
+				_, ok := h.(*CORSHandler)
+				return ok
+			}).(*CORSHandler)
+			if nil == cors || cors.allowsOrigin(r.Header.Get(CORSRequestOrigin)) {
+				w.Header().Set(CORSAllowMethods, strings.Join(methods, ", "))
+			}
+			if nil != cors {
 				cors.HandleCORS(w, r)
 			}
 		}
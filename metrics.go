@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"github.com/rcrowley/go-metrics"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -230,3 +232,134 @@ func (t *Timer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer t.UpdateSince(time.Now())
 	t.handler.ServeHTTP(w, r)
 }
+
+// TimerByRoute is an http.Handler that times requests and counts responses
+// by the first digit of their HTTP status code via go-metrics, separately
+// for each HTTP method and URL pattern in a TrieServeMux.
+type TimerByRoute struct {
+	mu       sync.Mutex
+	mux      *TrieServeMux
+	name     string
+	registry metrics.Registry
+	routes   map[string]*routeMetrics
+}
+
+type routeMetrics struct {
+	timer    metrics.Timer
+	counters [5]metrics.Counter
+}
+
+// TimedByRoute returns an http.Handler that routes requests through the
+// given TrieServeMux, times them, and counts the responses by the first
+// digit of their HTTP status code via go-metrics.  Metrics are registered
+// the first time each route is requested and are named for the route, so a
+// request routed to GET /stuff/{id} updates a timer called
+// "name-GET-stuff-id" and a counter called "name-GET-stuff-id-2xx".
+// Requests routed to TrieServeMuxes registered directly as namespaces are
+// named for the whole URL pattern.  Requests that aren't routed anywhere
+// update "name-unrouted" and its counters.  Responses that never call
+// WriteHeader are counted as 200 OK, as net/http sends them.
+func TimedByRoute(
+	mux *TrieServeMux,
+	name string,
+	registry metrics.Registry,
+) *TimerByRoute {
+	if nil == registry {
+		registry = metrics.DefaultRegistry
+	}
+	return &TimerByRoute{
+		mux:      mux,
+		name:     name,
+		registry: registry,
+		routes:   make(map[string]*routeMetrics),
+	}
+}
+
+// Handler returns the handler the underlying TrieServeMux would use for the
+// given HTTP request, like TrieServeMux.Handler.
+func (t *TimerByRoute) Handler(r *http.Request) (http.Handler, string) {
+	return t.mux.Handler(r)
+}
+
+// ServeHTTP routes the request through the TrieServeMux, starts a timer,
+// passes the request to the http.Handler it was routed to, stops the timer,
+// and updates the timer and counters for the route via go-metrics.
+func (t *TimerByRoute) ServeHTTP(w0 http.ResponseWriter, r *http.Request) {
+	r, handler, pattern := routeHandler(t.mux, r)
+	name := t.name + "-unrouted"
+	switch handler.(type) {
+	case MethodNotAllowedHandler, NotFoundHandler:
+	default:
+		name = routeMetricName(t.name, r.Method, pattern)
+	}
+	m := t.metrics(name)
+	w := NewTeeHeaderResponseWriter(w0)
+	defer func(start time.Time) {
+		m.timer.UpdateSince(start)
+		code := w.StatusCode
+		if 0 == code {
+			code = http.StatusOK
+		}
+		i := code/100 - 1
+		if i < 0 {
+			i = 0
+		} else if i > 4 {
+			i = 4
+		}
+		m.counters[i].Inc(1)
+	}(time.Now())
+	handler.ServeHTTP(w, r)
+}
+
+// metrics returns the metrics with the given name, registering them if this
+// is the first time they've been needed.
+func (t *TimerByRoute) metrics(name string) *routeMetrics {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if m, ok := t.routes[name]; ok {
+		return m
+	}
+	m := &routeMetrics{
+		timer: t.registry.GetOrRegister(name, metrics.NewTimer).(metrics.Timer),
+	}
+	for i := range m.counters {
+		m.counters[i] = t.registry.GetOrRegister(
+			fmt.Sprintf("%s-%dxx", name, i+1),
+			metrics.NewCounter,
+		).(metrics.Counter)
+	}
+	t.routes[name] = m
+	return m
+}
+
+// routeHandler returns the http.Handler the given TrieServeMux routes the
+// request to, the request to pass to it, and its URL pattern, descending
+// into TrieServeMuxes registered directly as namespaces so the pattern is
+// the whole URL's.
+func routeHandler(mux *TrieServeMux, r *http.Request) (*http.Request, http.Handler, string) {
+	r, handler, pattern := mux.route(r)
+	for {
+		mux, ok := handler.(*TrieServeMux)
+		if !ok {
+			return r, handler, pattern
+		}
+		var nested string
+		r, handler, nested = mux.route(r)
+		pattern += nested
+	}
+}
+
+// routeMetricName names metrics for an HTTP method and URL pattern in the
+// style of the example: GET /stuff/{id} becomes "GET-stuff-id".
+func routeMetricName(prefix, method, pattern string) string {
+	components := []string{prefix, method}
+	for _, segment := range strings.Split(pattern, "/") {
+		if param, _ := parseTrieParam(segment); nil != param {
+			segment = param.name
+		}
+		if "" != segment {
+			components = append(components, segment)
+		}
+	}
+	return strings.Join(components, "-")
+}
@@ -2,6 +2,7 @@ package tigertonic
 
 import (
 	"bytes"
+	"github.com/rcrowley/go-metrics"
 	"net/http"
 	"net/url"
 	"testing"
@@ -68,3 +69,44 @@ func TestTimer(t *testing.T) {
 		t.Fatal(timer.Count())
 	}
 }
+
+func TestTimerByRoute(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.HandleFunc("GET", "/stuff/{id:int}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	nsMux := NewTrieServeMux()
+	nsMux.HandleNamespace("/1.0", mux)
+	registry := metrics.NewRegistry()
+	timer := TimedByRoute(nsMux, "api", registry)
+	for _, path := range []string{"/1.0/stuff/47", "/1.0/stuff/48", "/1.0/nothing"} {
+		r, _ := http.NewRequest("GET", "http://example.com"+path, nil)
+		timer.ServeHTTP(&testResponseWriter{}, r)
+	}
+	if m, ok := registry.Get("api-GET-1.0-stuff-id").(metrics.Timer); !ok || 2 != m.Count() {
+		t.Fatal(registry.Get("api-GET-1.0-stuff-id"))
+	}
+	if m, ok := registry.Get("api-GET-1.0-stuff-id-2xx").(metrics.Counter); !ok || 2 != m.Count() {
+		t.Fatal(registry.Get("api-GET-1.0-stuff-id-2xx"))
+	}
+	if m, ok := registry.Get("api-unrouted-4xx").(metrics.Counter); !ok || 1 != m.Count() {
+		t.Fatal(registry.Get("api-unrouted-4xx"))
+	}
+}
+
+func TestTimerByRouteImplicitStatus(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.HandleFunc("GET", "/stuff", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("stuff"))
+	})
+	registry := metrics.NewRegistry()
+	timer := TimedByRoute(mux, "api", registry)
+	r, _ := http.NewRequest("GET", "http://example.com/stuff", nil)
+	timer.ServeHTTP(&testResponseWriter{}, r)
+	if m, ok := registry.Get("api-GET-stuff-2xx").(metrics.Counter); !ok || 1 != m.Count() {
+		t.Fatal(registry.Get("api-GET-stuff-2xx"))
+	}
+	if m, ok := registry.Get("api-GET-stuff-1xx").(metrics.Counter); !ok || 0 != m.Count() {
+		t.Fatal(registry.Get("api-GET-stuff-1xx"))
+	}
+}
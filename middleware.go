@@ -1,6 +1,9 @@
 package tigertonic
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 // FirstHandler is an http.Handler that, for each handler in its slice of
 // handlers, calls ServeHTTP until the first one that calls w.WriteHeader.
@@ -49,10 +52,39 @@ func (w *firstResponseWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+// IfContext is like If but the function also returns the context.Context
+// with which to call the other http.Handler so it can pass along what it
+// learned about the request, such as who made it.
+func IfContext(f func(*http.Request) (context.Context, http.Header, error), h http.Handler) FirstHandler {
+	return First(&ifContextHandler{f, h})
+}
+
 type ifHandler func(*http.Request) (http.Header, error)
 
 func (ih ifHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	header, err := ih(r)
+	writeIfError(w, r, header, err)
+}
+
+type ifContextHandler struct {
+	f       func(*http.Request) (context.Context, http.Header, error)
+	handler http.Handler
+}
+
+func (ih *ifContextHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, header, err := ih.f(r)
+	if writeIfError(w, r, header, err); nil != err {
+		return
+	}
+	if nil != ctx {
+		r = r.WithContext(ctx)
+	}
+	ih.handler.ServeHTTP(w, r)
+}
+
+// writeIfError sets the headers returned by an If or IfContext function and
+// writes its error, if there is one.
+func writeIfError(w http.ResponseWriter, r *http.Request, header http.Header, err error) {
 	for name, values := range header {
 		for _, value := range values {
 			w.Header().Set(name, value)
@@ -66,3 +98,66 @@ func (ih ifHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// unwrap returns the http.Handlers wrapped by the given http.Handler if it
+// is one of Tiger Tonic's own middlewares so that routes can be inspected
+// through them.
+func unwrap(h http.Handler) []http.Handler {
+	switch h := h.(type) {
+	case *AccessHandler:
+		return []http.Handler{h.handler}
+	case *ApacheLogger:
+		return []http.Handler{h.handler}
+	case *CacheControl:
+		return []http.Handler{h.handler}
+	case *ContextHandler:
+		return []http.Handler{h.handler}
+	case *CORSHandler:
+		return []http.Handler{h.Handler}
+	case *Counter:
+		return []http.Handler{h.handler}
+	case *CounterByStatus:
+		return []http.Handler{h.handler}
+	case *CounterByStatusXX:
+		return []http.Handler{h.handler}
+	case FirstHandler:
+		return h
+	case *ifContextHandler:
+		return []http.Handler{h.handler}
+	case *JSONLogger:
+		return []http.Handler{h.handler}
+	case *MultilineLogger:
+		return []http.Handler{h.handler}
+	case *PeerIdentityHandler:
+		return []http.Handler{h.handler}
+	case *PostProcessor:
+		return []http.Handler{h.handler}
+	case *RateLimiter:
+		return []http.Handler{h.handler}
+	case *Recoverer:
+		return []http.Handler{h.handler}
+	case *serverHandler:
+		return []http.Handler{h.Handler}
+	case *TimeoutHandler:
+		return []http.Handler{h.handler}
+	case *Timer:
+		return []http.Handler{h.handler}
+	case *TimerByRoute:
+		return []http.Handler{h.mux}
+	}
+	return nil
+}
+
+// findHandler searches the given http.Handler and every http.Handler it
+// wraps, depth-first, for the first one the given function accepts.
+func findHandler(h http.Handler, f func(http.Handler) bool) http.Handler {
+	if f(h) {
+		return h
+	}
+	for _, wrapped := range unwrap(h) {
+		if found := findHandler(wrapped, f); nil != found {
+			return found
+		}
+	}
+	return nil
+}
@@ -0,0 +1,556 @@
+package tigertonic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIDocument is an OpenAPI 3 description of the routes registered with
+// a TrieServeMux (or several, nested via HandleNamespace and HostServeMux)
+// and the request, response, and params types of their Marshaled handlers.
+type OpenAPIDocument struct {
+	OpenAPI    string                      `json:"openapi"`
+	Info       OpenAPIInfo                 `json:"info"`
+	Paths      map[string]*OpenAPIPathItem `json:"paths"`
+	Components OpenAPIComponents           `json:"components"`
+
+	schemaTypes map[string]reflect.Type
+}
+
+// OpenAPIInfo is the metadata about the API that can't be derived from the
+// code.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*OpenAPISchema `json:"schemas"`
+}
+
+type OpenAPIPathItem struct {
+	Servers []OpenAPIServer   `json:"servers,omitempty"`
+	Get     *OpenAPIOperation `json:"get,omitempty"`
+	Put     *OpenAPIOperation `json:"put,omitempty"`
+	Post    *OpenAPIOperation `json:"post,omitempty"`
+	Delete  *OpenAPIOperation `json:"delete,omitempty"`
+	Options *OpenAPIOperation `json:"options,omitempty"`
+	Head    *OpenAPIOperation `json:"head,omitempty"`
+	Patch   *OpenAPIOperation `json:"patch,omitempty"`
+	Trace   *OpenAPIOperation `json:"trace,omitempty"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+type OpenAPIOperation struct {
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *OpenAPISchema `json:"schema"`
+
+	// CatchAll marks path parameters written {name...} in the URL pattern,
+	// which OpenAPI can't express, that match the rest of the path,
+	// slashes and all.
+	CatchAll bool `json:"x-catch-all,omitempty"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPISchema is the subset of the OpenAPI 3 Schema Object that can be
+// derived from Go types, their json struct tags, and their validate struct
+// tags.
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	Enum                 []string                  `json:"enum,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty"`
+	MinLength            *int                      `json:"minLength,omitempty"`
+	MaxLength            *int                      `json:"maxLength,omitempty"`
+	MinItems             *int                      `json:"minItems,omitempty"`
+	MaxItems             *int                      `json:"maxItems,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+}
+
+// NewOpenAPIDocument walks the given http.Handler, which is usually a
+// TrieServeMux or HostServeMux possibly wrapped in other Tiger Tonic
+// middleware, and describes every route it finds.
+func NewOpenAPIDocument(handler http.Handler, info OpenAPIInfo) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   make(map[string]*OpenAPIPathItem),
+		Components: OpenAPIComponents{
+			Schemas: map[string]*OpenAPISchema{
+				"Error": {
+					Type: "object",
+					Properties: map[string]*OpenAPISchema{
+						"description": {Type: "string"},
+						"error":       {Type: "string"},
+						"fields": {
+							Type: "array",
+							Items: &OpenAPISchema{
+								Type: "object",
+								Properties: map[string]*OpenAPISchema{
+									"field":   {Type: "string"},
+									"message": {Type: "string"},
+								},
+							},
+						},
+					},
+					Required: []string{"description", "error"},
+				},
+			},
+		},
+	}
+	doc.add(handler)
+	return doc
+}
+
+// MarshalYAML returns the document as YAML.
+func (doc *OpenAPIDocument) MarshalYAML() ([]byte, error) {
+	buf, err := json.Marshal(doc)
+	if nil != err {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); nil != err {
+		return nil, err
+	}
+	b := &bytes.Buffer{}
+	writeYAML(b, v, 0)
+	return b.Bytes(), nil
+}
+
+// add describes every route reachable through the given http.Handler.
+func (doc *OpenAPIDocument) add(handler http.Handler) {
+	routes, _ := nestedRoutes(handler, "", "")
+	for _, route := range routes {
+		if "" != route.Method {
+			doc.addOperation(route.Method, route.Pattern, route.Host, route.Handler)
+		}
+	}
+}
+
+func (doc *OpenAPIDocument) addOperation(method, pattern, host string, h http.Handler) {
+	segments := strings.Split(pattern, "/")
+	var pathParams []*trieParam
+	for i, segment := range segments {
+		if param, _ := parseTrieParam(segment); nil != param {
+			pathParams = append(pathParams, param)
+			segments[i] = "{" + param.name + "}"
+		}
+	}
+	pattern = strings.Join(segments, "/")
+	item, ok := doc.Paths[pattern]
+	if !ok {
+		item = &OpenAPIPathItem{}
+		doc.Paths[pattern] = item
+	}
+	if "" != host {
+		server := OpenAPIServer{"//" + host}
+		found := false
+		for _, s := range item.Servers {
+			found = found || s == server
+		}
+		if !found {
+			item.Servers = append(item.Servers, server)
+		}
+	}
+	op := &OpenAPIOperation{
+		Responses: map[string]OpenAPIResponse{
+			"default": {
+				Description: "error",
+				Content: map[string]OpenAPIMediaType{
+					"application/json": {&OpenAPISchema{Ref: "#/components/schemas/Error"}},
+				},
+			},
+		},
+	}
+	var params reflect.Type
+	m, _ := findHandler(h, func(h http.Handler) bool {
+		_, ok := h.(*Marshaler)
+		return ok
+	}).(*Marshaler)
+	if nil != m {
+		params = m.params
+	}
+	for _, param := range pathParams {
+		schema := doc.paramSchema(params, "path", param.name)
+		if nil != param.re && "string" == schema.Type && "" == schema.Pattern {
+			schema.Pattern = param.re.String()
+		}
+		p := OpenAPIParameter{
+			Name:     param.name,
+			In:       "path",
+			Required: true,
+			Schema:   schema,
+		}
+		if param.catchAll {
+			p.Description = "The rest of the path, which may contain slashes."
+			p.CatchAll = true
+		}
+		op.Parameters = append(op.Parameters, p)
+	}
+	if nil != params {
+		t := params.Elem()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if name := f.Tag.Get("query"); "" != name {
+				rule, err := parseValidateTag(f.Tag.Get("validate"))
+				op.Parameters = append(op.Parameters, OpenAPIParameter{
+					Name:     name,
+					In:       "query",
+					Required: nil == err && rule.required,
+					Schema:   doc.schema(f.Type, f.Tag.Get("validate")),
+				})
+			}
+		}
+	}
+	success := OpenAPIResponse{Description: "success"}
+	if nil != m {
+		t := m.v.Type()
+		n := 2
+		if m.ctx {
+			n++
+		}
+		if nil != params {
+			n++
+		}
+		if n < t.NumIn() && !isEmptyInterface(t.In(n)) && ("PATCH" == method || "POST" == method || "PUT" == method) {
+			schema := doc.schema(t.In(n), "")
+			content := make(map[string]OpenAPIMediaType)
+			for _, mediaType := range codecTypes {
+				content[mediaType] = OpenAPIMediaType{schema}
+			}
+			op.RequestBody = &OpenAPIRequestBody{Required: true, Content: content}
+		}
+		if out := t.Out(2); reflect.Interface == out.Kind() && out.Implements(readerType) {
+			success.Content = map[string]OpenAPIMediaType{
+				"*/*": {&OpenAPISchema{Type: "string", Format: "binary"}},
+			}
+		} else if !isEmptyInterface(out) {
+			schema := doc.schema(out, "")
+			success.Content = make(map[string]OpenAPIMediaType)
+			for _, mediaType := range codecTypes {
+				success.Content[mediaType] = OpenAPIMediaType{schema}
+			}
+		}
+	}
+	op.Responses["2XX"] = success
+	switch method {
+	case "GET":
+		item.Get = op
+	case "PUT":
+		item.Put = op
+	case "POST":
+		item.Post = op
+	case "DELETE":
+		item.Delete = op
+	case "OPTIONS":
+		item.Options = op
+	case "HEAD":
+		item.Head = op
+	case "PATCH":
+		item.Patch = op
+	case "TRACE":
+		item.Trace = op
+	default:
+		log.Printf("OpenAPI can't describe %s %s\n", method, pattern)
+	}
+}
+
+// paramSchema returns the schema of the params struct field bound to the
+// given parameter or a string schema if there is no such field.
+func (doc *OpenAPIDocument) paramSchema(params reflect.Type, source, name string) *OpenAPISchema {
+	if nil != params {
+		t := params.Elem()
+		for i := 0; i < t.NumField(); i++ {
+			if name == t.Field(i).Tag.Get(source) {
+				return doc.schema(t.Field(i).Type, t.Field(i).Tag.Get("validate"))
+			}
+		}
+	}
+	return &OpenAPISchema{Type: "string"}
+}
+
+// schema derives a schema from a Go type as encoding/json would marshal it,
+// constrained by a validate struct tag.  Named struct types are added to
+// the document's components and referenced.
+func (doc *OpenAPIDocument) schema(t reflect.Type, tag string) *OpenAPISchema {
+	for reflect.Ptr == t.Kind() {
+		t = t.Elem()
+	}
+	s := &OpenAPISchema{}
+	switch {
+	case timeType == t:
+		s.Type, s.Format = "string", "date-time"
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		// Could be anything.
+	default:
+		switch t.Kind() {
+		case reflect.Bool:
+			s.Type = "boolean"
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+			s.Type, s.Format = "integer", "int32"
+		case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+			s.Type, s.Format = "integer", "int64"
+		case reflect.Float32:
+			s.Type, s.Format = "number", "float"
+		case reflect.Float64:
+			s.Type, s.Format = "number", "double"
+		case reflect.String:
+			s.Type = "string"
+		case reflect.Slice, reflect.Array:
+			if reflect.Uint8 == t.Elem().Kind() && reflect.Slice == t.Kind() {
+				s.Type, s.Format = "string", "byte"
+			} else {
+				s.Type, s.Items = "array", doc.schema(t.Elem(), "")
+			}
+		case reflect.Map:
+			s.Type, s.AdditionalProperties = "object", doc.schema(t.Elem(), "")
+		case reflect.Struct:
+			if "" == t.Name() {
+				s = doc.structSchema(t)
+				break
+			}
+			name := doc.schemaName(t)
+			if _, ok := doc.Components.Schemas[name]; !ok {
+				doc.Components.Schemas[name] = &OpenAPISchema{} // Allow recursion.
+				*doc.Components.Schemas[name] = *doc.structSchema(t)
+			}
+			s.Ref = "#/components/schemas/" + name
+		}
+	}
+	if "" != tag && "" == s.Ref {
+		if rule, err := parseValidateTag(tag); nil == err {
+			constrainSchema(s, rule)
+		}
+	}
+	return s
+}
+
+func (doc *OpenAPIDocument) structSchema(t reflect.Type) *OpenAPISchema {
+	s := &OpenAPISchema{
+		Type:       "object",
+		Properties: make(map[string]*OpenAPISchema),
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if "" != f.PkgPath {
+			continue
+		}
+		name := jsonFieldName(f)
+		if "-" == name {
+			continue
+		}
+		if f.Anonymous && "" == f.Tag.Get("json") && reflect.Struct == f.Type.Kind() {
+			embedded := doc.structSchema(f.Type)
+			for name, property := range embedded.Properties {
+				s.Properties[name] = property
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		tag := f.Tag.Get("validate")
+		s.Properties[name] = doc.schema(f.Type, tag)
+		if rule, err := parseValidateTag(tag); nil == err && rule.required {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+// schemaName names a struct type in the document's components, qualifying
+// it with its package path if another type already has the same name.
+func (doc *OpenAPIDocument) schemaName(t reflect.Type) string {
+	name := t.Name()
+	if s, ok := doc.schemaTypes[name]; ok && s != t {
+		name = strings.Replace(t.PkgPath(), "/", ".", -1) + "." + name
+	}
+	if nil == doc.schemaTypes {
+		doc.schemaTypes = make(map[string]reflect.Type)
+	}
+	doc.schemaTypes[name] = t
+	return name
+}
+
+func constrainSchema(s *OpenAPISchema, rule *validateRule) {
+	switch s.Type {
+	case "string":
+		if nil != rule.min {
+			n := int(*rule.min)
+			s.MinLength = &n
+		}
+		if nil != rule.max {
+			n := int(*rule.max)
+			s.MaxLength = &n
+		}
+		if nil != rule.length {
+			s.MinLength, s.MaxLength = rule.length, rule.length
+		}
+		if nil != rule.re {
+			s.Pattern = rule.re.String()
+		}
+	case "array":
+		if nil != rule.min {
+			n := int(*rule.min)
+			s.MinItems = &n
+		}
+		if nil != rule.max {
+			n := int(*rule.max)
+			s.MaxItems = &n
+		}
+		if nil != rule.length {
+			s.MinItems, s.MaxItems = rule.length, rule.length
+		}
+	case "integer", "number":
+		s.Minimum, s.Maximum = rule.min, rule.max
+	}
+	s.Enum = rule.enum
+}
+
+// OpenAPIHandler is an http.Handler that responds with an OpenAPIDocument
+// describing another http.Handler, freshly generated for every request so
+// it can be registered in the very TrieServeMux it describes.  It responds
+// with YAML if the Accept header prefers it and JSON otherwise.
+type OpenAPIHandler struct {
+	Handler http.Handler
+	Info    OpenAPIInfo
+}
+
+func (h *OpenAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	doc := NewOpenAPIDocument(h.Handler, h.Info)
+	var (
+		buf []byte
+		err error
+	)
+	contentType := NegotiateContentType(r, "application/json", "application/yaml", "application/x-yaml", "text/yaml")
+	switch contentType {
+	case "application/yaml", "application/x-yaml", "text/yaml":
+		buf, err = doc.MarshalYAML()
+	default:
+		contentType = "application/json"
+		buf, err = json.MarshalIndent(doc, "", "  ")
+	}
+	if nil != err {
+		ResponseErrorWriter.WriteError(r, w, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf)
+}
+
+func isEmptyInterface(t reflect.Type) bool {
+	return reflect.Interface == t.Kind() && 0 == t.NumMethod()
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	readerType        = reflect.TypeOf((*io.Reader)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+	yamlPlainKey      = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+)
+
+// writeYAML writes a value decoded from JSON as block-style YAML.  Strings
+// are written as double-quoted JSON strings, which are valid YAML.
+func writeYAML(b *bytes.Buffer, v interface{}, indent int) {
+	pad := strings.Repeat(" ", indent)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if yamlPlainKey.MatchString(key) {
+				b.WriteString(pad + key + ":")
+			} else {
+				b.WriteString(pad + strconv.Quote(key) + ":")
+			}
+			writeYAMLChild(b, v[key], indent)
+		}
+	case []interface{}:
+		for _, elem := range v {
+			if m, ok := elem.(map[string]interface{}); ok && 0 != len(m) {
+				item := &bytes.Buffer{}
+				writeYAML(item, m, indent+2)
+				b.WriteString(pad + "- ")
+				b.Write(item.Bytes()[indent+2:])
+				continue
+			}
+			b.WriteString(pad + "-")
+			writeYAMLChild(b, elem, indent)
+		}
+	}
+}
+
+// writeYAMLChild writes a value following a "key:" or "-" that has already
+// been written, inline if it's a scalar or empty and on the following lines
+// otherwise.
+func writeYAMLChild(b *bytes.Buffer, v interface{}, indent int) {
+	switch child := v.(type) {
+	case map[string]interface{}:
+		if 0 == len(child) {
+			b.WriteString(" {}\n")
+			return
+		}
+	case []interface{}:
+		if 0 == len(child) {
+			b.WriteString(" []\n")
+			return
+		}
+	case string:
+		b.WriteString(" " + strconv.Quote(child) + "\n")
+		return
+	case nil:
+		b.WriteString(" null\n")
+		return
+	default:
+		fmt.Fprintf(b, " %v\n", child)
+		return
+	}
+	b.WriteString("\n")
+	writeYAML(b, v, indent+2)
+}
@@ -0,0 +1,154 @@
+package tigertonic
+
+import (
+	"encoding/json"
+	"github.com/rcrowley/go-metrics"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	doc := NewOpenAPIDocument(testOpenAPIHandler(), OpenAPIInfo{Title: "test", Version: "1.0"})
+	item, ok := doc.Paths["/1.0/stuff/{id}"]
+	if !ok {
+		t.Fatal(doc.Paths)
+	}
+	if 1 != len(item.Servers) || "//example.com" != item.Servers[0].URL {
+		t.Fatal(item.Servers)
+	}
+	if nil == item.Get || nil == item.Post || nil != item.Put {
+		t.Fatal(item)
+	}
+	if 2 != len(item.Get.Parameters) {
+		t.Fatal(item.Get.Parameters)
+	}
+	if p := item.Get.Parameters[0]; "id" != p.Name || "path" != p.In || !p.Required || "integer" != p.Schema.Type {
+		t.Fatal(p)
+	}
+	if p := item.Get.Parameters[1]; "limit" != p.Name || "query" != p.In || p.Required || 100 != *p.Schema.Maximum {
+		t.Fatal(p)
+	}
+	if nil != item.Get.RequestBody {
+		t.Fatal(item.Get.RequestBody)
+	}
+	if "#/components/schemas/testOpenAPIRequest" != item.Post.RequestBody.Content["application/json"].Schema.Ref {
+		t.Fatal(item.Post.RequestBody)
+	}
+	if "#/components/schemas/testResponse" != item.Get.Responses["2XX"].Content["application/json"].Schema.Ref {
+		t.Fatal(item.Get.Responses)
+	}
+	schema := doc.Components.Schemas["testOpenAPIRequest"]
+	if "string" != schema.Properties["name"].Type || 64 != *schema.Properties["name"].MaxLength {
+		t.Fatal(schema.Properties["name"])
+	}
+	if "array" != schema.Properties["children"].Type || "#/components/schemas/testOpenAPIRequest" != schema.Properties["children"].Items.Ref {
+		t.Fatal(schema.Properties["children"])
+	}
+	if "string" != schema.Properties["when"].Type || "date-time" != schema.Properties["when"].Format {
+		t.Fatal(schema.Properties["when"])
+	}
+	if 1 != len(schema.Required) || "name" != schema.Required[0] {
+		t.Fatal(schema.Required)
+	}
+	if _, ok := doc.Paths["/stuff/{id}"]; !ok {
+		t.Fatal(doc.Paths)
+	}
+	if _, ok := doc.Paths["/version"]; !ok {
+		t.Fatal(doc.Paths)
+	}
+}
+
+func TestOpenAPIDocumentCatchAll(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.HandleFunc("GET", "/files/{path...}", func(w http.ResponseWriter, r *http.Request) {})
+	doc := NewOpenAPIDocument(mux, OpenAPIInfo{Title: "test", Version: "1.0"})
+	item, ok := doc.Paths["/files/{path}"]
+	if !ok {
+		t.Fatal(doc.Paths)
+	}
+	if 1 != len(item.Get.Parameters) {
+		t.Fatal(item.Get.Parameters)
+	}
+	if p := item.Get.Parameters[0]; "path" != p.Name || "path" != p.In || !p.CatchAll || "" == p.Description {
+		t.Fatal(p)
+	}
+	b, err := json.Marshal(item.Get.Parameters[0])
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"x-catch-all":true`) {
+		t.Fatal(string(b))
+	}
+}
+
+func TestOpenAPIHandlerJSON(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/openapi", nil)
+	(&OpenAPIHandler{testOpenAPIHandler(), OpenAPIInfo{Title: "test", Version: "1.0"}}).ServeHTTP(w, r)
+	if "application/json" != w.Header().Get("Content-Type") {
+		t.Fatal(w.Header().Get("Content-Type"))
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); nil != err {
+		t.Fatal(err)
+	}
+	if "3.0.3" != doc["openapi"] {
+		t.Fatal(doc["openapi"])
+	}
+}
+
+func TestOpenAPIHandlerYAML(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/openapi", nil)
+	r.Header.Set("Accept", "application/yaml")
+	(&OpenAPIHandler{testOpenAPIHandler(), OpenAPIInfo{Title: "test", Version: "1.0"}}).ServeHTTP(w, r)
+	if "application/yaml" != w.Header().Get("Content-Type") {
+		t.Fatal(w.Header().Get("Content-Type"))
+	}
+	for _, line := range []string{
+		"openapi: \"3.0.3\"\n",
+		"info:\n  title: \"test\"\n  version: \"1.0\"\n",
+		"  \"/1.0/stuff/{id}\":\n",
+		"        - in: \"path\"\n          name: \"id\"\n          required: true\n",
+		"            $ref: \"#/components/schemas/testOpenAPIRequest\"\n",
+	} {
+		if !strings.Contains(w.Body.String(), line) {
+			t.Errorf("%q not found in\n%s", line, w.Body.String())
+		}
+	}
+}
+
+func testOpenAPIHandler() http.Handler {
+	mux := NewTrieServeMux()
+	mux.Handle("GET", "/stuff/{id:int}", Timed(Marshaled(func(u *url.URL, h http.Header, p *testOpenAPIParams) (int, http.Header, *testResponse, error) {
+		return http.StatusOK, nil, nil, nil
+	}), "test-openapi-get", metrics.NewRegistry()))
+	mux.Handle("POST", "/stuff/{id:int}", If(
+		func(r *http.Request) (http.Header, error) { return nil, nil },
+		Marshaled(func(u *url.URL, h http.Header, p *testOpenAPIParams, rq *testOpenAPIRequest) (int, http.Header, *testResponse, error) {
+			return http.StatusOK, nil, nil, nil
+		}),
+	))
+	mux.Handle("GET", "/version", Version("1.0"))
+	nsMux := NewTrieServeMux()
+	nsMux.HandleNamespace("", mux)
+	nsMux.HandleNamespace("/1.0", mux)
+	hMux := NewHostServeMux()
+	hMux.Handle("example.com", Logged(nsMux, nil))
+	return WithContext(hMux, testContext{})
+}
+
+type testOpenAPIParams struct {
+	Params
+	ID    int `path:"id"`
+	Limit int `query:"limit" validate:"max=100"`
+}
+
+type testOpenAPIRequest struct {
+	Name     string                `json:"name" validate:"required,max=64"`
+	Children []*testOpenAPIRequest `json:"children"`
+	When     time.Time             `json:"when"`
+}
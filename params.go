@@ -0,0 +1,130 @@
+package tigertonic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Params marks a struct that embeds it as a params struct, which Marshaled
+// fills from URL wildcards and query parameters instead of decoding it from
+// the request body.
+type Params struct{}
+
+var paramsType = reflect.TypeOf(Params{})
+
+// ParamError describes a URL path or query parameter that couldn't be
+// converted to the type of the params struct field it's bound to.
+type ParamError struct {
+	Source string // "path" or "query"
+	Param  string
+	Err    error
+}
+
+func (err ParamError) Error() string {
+	return fmt.Sprintf("%s parameter %q: %s", err.Source, err.Param, err.Err)
+}
+
+// isParamsType reports whether the given type is a pointer to a struct that
+// embeds Params, which Marshaled treats as a params struct rather than a
+// request body.
+func isParamsType(t reflect.Type) bool {
+	if reflect.Ptr != t.Kind() || reflect.Struct != t.Elem().Kind() {
+		return false
+	}
+	t = t.Elem()
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.Anonymous && paramsType == f.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// paramSource returns which tag, path or query, binds the given struct
+// field to a parameter, if either.
+func paramSource(f reflect.StructField) string {
+	if "" != f.Tag.Get("path") {
+		return "path"
+	}
+	if "" != f.Tag.Get("query") {
+		return "query"
+	}
+	return ""
+}
+
+// checkParamsType ensures every tagged field of a params struct is of a type
+// setFromStrings can convert to and that no query parameter is named like a
+// wildcard.
+func checkParamsType(t reflect.Type) error {
+	t = t.Elem()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if name := f.Tag.Get("query"); strings.HasPrefix(name, "{") && strings.HasSuffix(name, "}") {
+			return fmt.Errorf("query parameter %q can't be a wildcard; tag it path:%q", name, name[1:len(name)-1])
+		}
+		if source := paramSource(f); "" != source && !canSetFromStrings(f.Type) {
+			return fmt.Errorf(
+				"%s parameter %q can't be converted to %v",
+				source,
+				f.Tag.Get(source),
+				f.Type,
+			)
+		}
+	}
+	return nil
+}
+
+// bindParams allocates a new params struct of the given pointer type and
+// fills its tagged fields from the request.  Path parameters are read from
+// the wildcards TrieServeMux matched, which it adds to the request's
+// context.Context, rather than from the "{name}" query parameters it also
+// adds, since a client may send those itself when the matched URL pattern
+// has no such wildcard.
+func bindParams(t reflect.Type, r *http.Request) (reflect.Value, error) {
+	params := reflect.New(t.Elem())
+	v := params.Elem()
+	query, pathParams := r.URL.Query(), pathParamsFromContext(r.Context())
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		source := paramSource(f)
+		if "" == source {
+			continue
+		}
+		name, values := f.Tag.Get(source), query[f.Tag.Get(source)]
+		if "path" == source {
+			values = pathParams[name]
+		}
+		if err := setFromStrings(v.Field(i), values); nil != err {
+			return params, ParamError{source, name, err}
+		}
+	}
+	return params, nil
+}
+
+// pathParamsFromContext returns the wildcards every TrieServeMux that routed
+// the request matched, keyed by name without braces.
+func pathParamsFromContext(ctx context.Context) url.Values {
+	params, _ := ctx.Value(pathParamsKey{}).(url.Values)
+	return params
+}
+
+// withPathParams adds the "{name}" wildcards in the given url.Values to those
+// already in the context.Context.
+func withPathParams(ctx context.Context, params url.Values) context.Context {
+	merged := make(url.Values)
+	for name, values := range pathParamsFromContext(ctx) {
+		merged[name] = values
+	}
+	for key, values := range params {
+		if strings.HasPrefix(key, "{") && strings.HasSuffix(key, "}") {
+			merged[key[1:len(key)-1]] = values
+		}
+	}
+	return context.WithValue(ctx, pathParamsKey{}, merged)
+}
+
+type pathParamsKey struct{}
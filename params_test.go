@@ -0,0 +1,205 @@
+package tigertonic
+
+import (
+	"bytes"
+	"github.com/rcrowley/go-metrics"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMarshaledParams(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.Handle("GET", "/stuff/{id}", Marshaled(func(u *url.URL, h http.Header, p *testParams) (int, http.Header, *testResponse, error) {
+		if 47 != p.ID {
+			t.Error(p.ID)
+		}
+		if 10 != p.Limit || !p.Verbose || nil == p.Since || 2014 != p.Since.Year() {
+			t.Error(p)
+		}
+		if !reflect.DeepEqual([]string{"a", "b"}, p.Tags) || 5*time.Second != p.Timeout {
+			t.Error(p)
+		}
+		return http.StatusNoContent, nil, nil, nil
+	}))
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/stuff/47?limit=10&verbose=true&since=2014-01-15T00:00:00Z&tags=a&tags=b&timeout=5s", nil)
+	mux.ServeHTTP(w, r)
+	if http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+}
+
+func TestParamsAndBody(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.Handle("POST", "/stuff/{id}", Marshaled(func(u *url.URL, h http.Header, p *testParams, rq *testRequest) (int, http.Header, *testResponse, error) {
+		if 47 != p.ID || "bar" != rq.Foo {
+			t.Error(p, rq)
+		}
+		return http.StatusNoContent, nil, nil, nil
+	}))
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("POST", "http://example.com/stuff/47", bytes.NewBufferString("{\"foo\":\"bar\"}"))
+	r.Header.Set("Content-Type", "application/json")
+	mux.ServeHTTP(w, r)
+	if http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+}
+
+func TestParamsBadRequest(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.Handle("GET", "/stuff/{id}", Marshaled(func(u *url.URL, h http.Header, p *testParams) (int, http.Header, *testResponse, error) {
+		t.Fatal("handler called with bad params")
+		return http.StatusNoContent, nil, nil, nil
+	}))
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/stuff/47?limit=ten", nil)
+	r.Header.Set("Accept", "application/json")
+	mux.ServeHTTP(w, r)
+	if http.StatusBadRequest != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "{\"description\":\"query parameter \\\"limit\\\": strconv.ParseInt: parsing \\\"ten\\\": invalid syntax\",\"error\":\"tigertonic.ParamError\"}\n" != w.Body.String() {
+		t.Fatal(w.Body.String())
+	}
+}
+
+func TestParamsValidate(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.Handle("GET", "/stuff/{id}", Marshaled(func(u *url.URL, h http.Header, p *testParams) (int, http.Header, *testResponse, error) {
+		t.Fatal("handler called with invalid params")
+		return http.StatusNoContent, nil, nil, nil
+	}))
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/stuff/47?limit=1000", nil)
+	r.Header.Set("Accept", "application/json")
+	mux.ServeHTTP(w, r)
+	if http.StatusUnprocessableEntity != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "{\"description\":\"limit: must be at most 100\",\"error\":\"tigertonic.ValidationError\",\"fields\":[{\"field\":\"limit\",\"message\":\"must be at most 100\"}]}\n" != w.Body.String() {
+		t.Fatal(w.Body.String())
+	}
+}
+
+func TestParamsCollidingQueryParam(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.Handle("GET", "/stuff/{id}", Marshaled(func(u *url.URL, h http.Header, p *testParams) (int, http.Header, *testResponse, error) {
+		if 47 != p.ID || 47 != p.QueryID {
+			t.Error(p.ID, p.QueryID)
+		}
+		return http.StatusNoContent, nil, nil, nil
+	}))
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/stuff/47?id=48&%7Bid%7D=49", nil)
+	mux.ServeHTTP(w, r)
+	if http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+}
+
+func TestMarshaledPanicParamsType(t *testing.T) {
+	testMarshaledPanic(func(u *url.URL, h http.Header, p *struct {
+		Params
+		Foo map[string]string `query:"foo"`
+	}) (int, http.Header, *testResponse, error) {
+		return 0, nil, nil, nil
+	}, t)
+	testMarshaledPanic(func(u *url.URL, h http.Header, p *struct {
+		Params
+		ID string `query:"{id}"`
+	}) (int, http.Header, *testResponse, error) {
+		return 0, nil, nil, nil
+	}, t)
+}
+
+func TestParamsForgedWildcard(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.Handle("GET", "/stuff", Marshaled(func(u *url.URL, h http.Header, p *testParams) (int, http.Header, *testResponse, error) {
+		if 0 != p.ID {
+			t.Error(p.ID)
+		}
+		return http.StatusNoContent, nil, nil, nil
+	}))
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/stuff?%7Bid%7D=49", nil)
+	mux.ServeHTTP(w, r)
+	if http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+}
+
+func TestParamsNamespace(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.Handle("GET", "/stuff/{id}", Marshaled(func(u *url.URL, h http.Header, p *struct {
+		Params
+		Version string `path:"version"`
+		ID      int    `path:"id"`
+	}) (int, http.Header, *testResponse, error) {
+		if "1.0" != p.Version || 47 != p.ID {
+			t.Error(p.Version, p.ID)
+		}
+		return http.StatusNoContent, nil, nil, nil
+	}))
+	nsMux := NewTrieServeMux()
+	nsMux.HandleNamespace("/{version}", mux)
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/1.0/stuff/47", nil)
+	nsMux.ServeHTTP(w, r)
+	if http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+}
+
+func TestParamsLeaveRequestAlone(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.Handle("GET", "/stuff/{id}", Marshaled(func(u *url.URL, h http.Header, p *testParams) (int, http.Header, *testResponse, error) {
+		if 47 != p.ID {
+			t.Error(p.ID)
+		}
+		return http.StatusNoContent, nil, nil, nil
+	}))
+	r, _ := http.NewRequest("GET", "http://example.com/stuff/47", nil)
+	ctx := r.Context()
+	for _, h := range []http.Handler{mux, TimedByRoute(mux, "test", metrics.NewRegistry())} {
+		w := &testResponseWriter{}
+		h.ServeHTTP(w, r)
+		if http.StatusNoContent != w.StatusCode {
+			t.Fatal(w.StatusCode, w.Body.String())
+		}
+		if ctx != r.Context() {
+			t.Fatal("request's context.Context replaced")
+		}
+	}
+}
+
+func TestUntaggedParamsIsRequestBody(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("POST", "http://example.com/stuff", bytes.NewBufferString(`{"ID":"foo"}`))
+	r.Header.Set("Content-Type", "application/json")
+	Marshaled(func(u *url.URL, h http.Header, rq *struct {
+		ID string `query:"id"`
+	}) (int, http.Header, *testResponse, error) {
+		if "foo" != rq.ID {
+			t.Error(rq.ID)
+		}
+		return http.StatusNoContent, nil, nil, nil
+	}).ServeHTTP(w, r)
+	if http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode, w.Body.String())
+	}
+}
+
+type testParams struct {
+	Params
+	ID      int           `path:"id"`
+	QueryID int           `query:"id"`
+	Limit   int           `query:"limit" validate:"max=100"`
+	Verbose bool          `query:"verbose"`
+	Since   *time.Time    `query:"since"`
+	Tags    []string      `query:"tags"`
+	Timeout time.Duration `query:"timeout"`
+}
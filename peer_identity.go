@@ -0,0 +1,134 @@
+package tigertonic
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// PeerIdentity is who a verified TLS client certificate says the client is.
+type PeerIdentity struct {
+	CommonName string   `json:"cn,omitempty"`
+	DNSNames   []string `json:"dns,omitempty"`
+	Emails     []string `json:"emails,omitempty"`
+	URIs       []string `json:"uris,omitempty"`
+	SPIFFEID   string   `json:"spiffe_id,omitempty"`
+}
+
+// NewPeerIdentity extracts the subject common name and subject alternative
+// names from a certificate.  The first URI with the spiffe scheme, if any,
+// is also its SPIFFE ID.
+func NewPeerIdentity(c *x509.Certificate) *PeerIdentity {
+	id := &PeerIdentity{
+		CommonName: c.Subject.CommonName,
+		DNSNames:   c.DNSNames,
+		Emails:     c.EmailAddresses,
+	}
+	for _, u := range c.URIs {
+		id.URIs = append(id.URIs, u.String())
+		if "spiffe" == u.Scheme && "" == id.SPIFFEID {
+			id.SPIFFEID = u.String()
+		}
+	}
+	return id
+}
+
+// RequestPeerIdentity returns the identity in the request's verified TLS
+// client certificate or nil if there isn't one.
+func RequestPeerIdentity(r *http.Request) *PeerIdentity {
+	if id, ok := r.Context().Value(peerIdentityKey{}).(*PeerIdentity); ok {
+		return id
+	}
+	if nil == r.TLS || 0 == len(r.TLS.VerifiedChains) || 0 == len(r.TLS.VerifiedChains[0]) {
+		return nil
+	}
+	return NewPeerIdentity(r.TLS.VerifiedChains[0][0])
+}
+
+// PeerIdentityFromContext returns the identity added to the context.Context
+// by WithPeerIdentity, as passed to Marshaled functions that take one, or nil
+// if there isn't one.
+func PeerIdentityFromContext(ctx context.Context) *PeerIdentity {
+	id, _ := ctx.Value(peerIdentityKey{}).(*PeerIdentity)
+	return id
+}
+
+// Is reports whether any of the identity's names is the given name.  A name
+// ending with an asterisk matches any name that begins with what precedes
+// it, as in "spiffe://example.com/*".
+func (id *PeerIdentity) Is(name string) bool {
+	match := func(s string) bool { return s == name }
+	if strings.HasSuffix(name, "*") {
+		prefix := strings.TrimSuffix(name, "*")
+		match = func(s string) bool { return strings.HasPrefix(s, prefix) }
+	}
+	if "" != id.CommonName && match(id.CommonName) {
+		return true
+	}
+	for _, names := range [][]string{id.DNSNames, id.Emails, id.URIs} {
+		for _, s := range names {
+			if match(s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// String returns the identity's SPIFFE ID if it has one and its common name
+// or first DNS name otherwise.
+func (id *PeerIdentity) String() string {
+	if "" != id.SPIFFEID {
+		return id.SPIFFEID
+	}
+	if "" == id.CommonName && 0 != len(id.DNSNames) {
+		return id.DNSNames[0]
+	}
+	return id.CommonName
+}
+
+type peerIdentityKey struct{}
+
+// PeerIdentityHandler is an http.Handler that adds the identity in each
+// request's verified TLS client certificate to its context.Context.
+type PeerIdentityHandler struct {
+	handler http.Handler
+}
+
+// WithPeerIdentity returns an http.Handler that adds the identity in each
+// request's verified TLS client certificate, if there is one, to its
+// context.Context, where PeerIdentityFromContext can find it.
+func WithPeerIdentity(handler http.Handler) *PeerIdentityHandler {
+	return &PeerIdentityHandler{handler}
+}
+
+// ServeHTTP adds the identity to the request's context.Context and calls the
+// wrapped http.Handler.
+func (h *PeerIdentityHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if id := RequestPeerIdentity(r); nil != id {
+		r = r.WithContext(context.WithValue(r.Context(), peerIdentityKey{}, id))
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+// PeerIdentityAllowed returns an http.Handler that conditionally calls
+// another http.Handler if the request's verified TLS client certificate
+// identifies any of the given names, as in PeerIdentity.Is.  Otherwise,
+// respond 403 Forbidden.
+func PeerIdentityAllowed(names []string, h http.Handler) FirstHandler {
+	return If(func(r *http.Request) (http.Header, error) {
+		id := RequestPeerIdentity(r)
+		if nil == id {
+			return nil, Forbidden{errors.New("no verified client certificate")}
+		}
+		for _, name := range names {
+			if id.Is(name) {
+				return nil, nil
+			}
+		}
+		return nil, Forbidden{fmt.Errorf("%s is not allowed", id)}
+	}, h)
+}
@@ -0,0 +1,150 @@
+package tigertonic
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPeerIdentity(t *testing.T) {
+	id := RequestPeerIdentity(testPeerRequest())
+	if "client.example.com" != id.CommonName {
+		t.Fatal(id.CommonName)
+	}
+	if 1 != len(id.DNSNames) || "api.example.com" != id.DNSNames[0] {
+		t.Fatal(id.DNSNames)
+	}
+	if 1 != len(id.Emails) || "ops@example.com" != id.Emails[0] {
+		t.Fatal(id.Emails)
+	}
+	if 2 != len(id.URIs) {
+		t.Fatal(id.URIs)
+	}
+	if "spiffe://example.com/ns/prod/sa/api" != id.SPIFFEID {
+		t.Fatal(id.SPIFFEID)
+	}
+	if "spiffe://example.com/ns/prod/sa/api" != id.String() {
+		t.Fatal(id.String())
+	}
+}
+
+func TestPeerIdentityUnverified(t *testing.T) {
+	r, _ := http.NewRequest("GET", "https://example.com/foo", nil)
+	if nil != RequestPeerIdentity(r) {
+		t.Fatal("identity without TLS")
+	}
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{testPeerCertificate()}}
+	if nil != RequestPeerIdentity(r) {
+		t.Fatal("identity from an unverified certificate")
+	}
+}
+
+func TestPeerIdentityIs(t *testing.T) {
+	id := RequestPeerIdentity(testPeerRequest())
+	for _, name := range []string{
+		"client.example.com",
+		"api.example.com",
+		"ops@example.com",
+		"spiffe://example.com/ns/prod/sa/api",
+		"spiffe://example.com/ns/prod/*",
+	} {
+		if !id.Is(name) {
+			t.Fatal(name)
+		}
+	}
+	for _, name := range []string{"", "example.com", "spiffe://example.com/ns/dev/*"} {
+		if id.Is(name) {
+			t.Fatal(name)
+		}
+	}
+}
+
+func TestWithPeerIdentity(t *testing.T) {
+	w := &testResponseWriter{}
+	var id *PeerIdentity
+	WithPeerIdentity(Marshaled(func(ctx context.Context, u *url.URL, h http.Header, _ interface{}) (int, http.Header, interface{}, error) {
+		id = PeerIdentityFromContext(ctx)
+		return http.StatusNoContent, nil, nil, nil
+	})).ServeHTTP(w, testPeerRequest())
+	if http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if nil == id || "client.example.com" != id.CommonName {
+		t.Fatal(id)
+	}
+}
+
+func TestPeerIdentityAllowed(t *testing.T) {
+	h := PeerIdentityAllowed([]string{"spiffe://example.com/ns/prod/*"}, NotFoundHandler{})
+	w := &testResponseWriter{}
+	h.ServeHTTP(w, testPeerRequest())
+	if http.StatusNotFound != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+}
+
+func TestPeerIdentityForbidden(t *testing.T) {
+	h := PeerIdentityAllowed([]string{"spiffe://example.com/ns/dev/*"}, NotFoundHandler{})
+	w := &testResponseWriter{}
+	h.ServeHTTP(w, testPeerRequest())
+	if http.StatusForbidden != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	w = &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "https://example.com/foo", nil)
+	h.ServeHTTP(w, r)
+	if http.StatusForbidden != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+}
+
+func TestApacheLoggerPeerIdentity(t *testing.T) {
+	r := testPeerRequest()
+	r.RemoteAddr = "127.0.0.1:48879"
+	r.RequestURI = "/foo"
+	logger := ApacheLogged(NotFoundHandler{})
+	b := &bytes.Buffer{}
+	logger.Logger = log.New(b, "", 0)
+	logger.ServeHTTP(&testResponseWriter{}, r)
+	if s := b.String(); !strings.HasPrefix(s, "127.0.0.1 - spiffe://example.com/ns/prod/sa/api [") {
+		t.Fatal(s)
+	}
+}
+
+func TestJSONLoggerPeerIdentity(t *testing.T) {
+	logger := JSONLogged(NotFoundHandler{}, nil)
+	b := &bytes.Buffer{}
+	logger.Logger = log.New(b, "", 0)
+	logger.ServeHTTP(&testResponseWriter{}, testPeerRequest())
+	if s := b.String(); !strings.Contains(s, `"peer":{"cn":"client.example.com",`) {
+		t.Fatal(s)
+	}
+}
+
+func testPeerCertificate() *x509.Certificate {
+	spiffe, _ := url.Parse("spiffe://example.com/ns/prod/sa/api")
+	other, _ := url.Parse("https://example.com/client")
+	return &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "client.example.com"},
+		DNSNames:       []string{"api.example.com"},
+		EmailAddresses: []string{"ops@example.com"},
+		URIs:           []*url.URL{other, spiffe},
+	}
+}
+
+func testPeerRequest() *http.Request {
+	r, _ := http.NewRequest("GET", "https://example.com/foo", nil)
+	c := testPeerCertificate()
+	r.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{c},
+		VerifiedChains:   [][]*x509.Certificate{{c}},
+	}
+	return r
+}
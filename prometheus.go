@@ -0,0 +1,162 @@
+package tigertonic
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/rcrowley/go-metrics"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PrometheusHandler is an http.Handler that responds with every metric in a
+// go-metrics registry in the Prometheus text exposition format.
+//
+// Counters and meters become Prometheus counters, gauges become gauges, and
+// histograms and timers become summaries of their 50th, 75th, 95th, 99th, and
+// 99.9th percentiles.  Timers are reported in seconds.  Metric names are
+// sanitized to satisfy Prometheus and a trailing HTTP status code or class,
+// as registered by CountedByStatus, CountedByStatusXX, and TimedByRoute,
+// becomes a status label, so "http-2xx" is reported as http_total{status="2xx"}.
+type PrometheusHandler struct {
+	Registry  metrics.Registry // metrics.DefaultRegistry if nil
+	Namespace string           // prepended to every metric name if not empty
+}
+
+// ServeHTTP responds 200 with the current value of every metric.
+func (h *PrometheusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	registry := h.Registry
+	if nil == registry {
+		registry = metrics.DefaultRegistry
+	}
+	all := make(map[string]interface{})
+	registry.Each(func(name string, i interface{}) {
+		all[name] = i
+	})
+	names := make([]string, 0, len(all))
+	for name := range all {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	families := make(prometheusFamilies)
+	for _, name := range names {
+		i := all[name]
+		var labels string
+		if m := prometheusStatusSuffix.FindStringSubmatch(name); nil != m {
+			name, labels = strings.TrimSuffix(name, m[0]), fmt.Sprintf("status=%q", m[1])
+		}
+		name = prometheusName(h.Namespace, name)
+		switch m := i.(type) {
+		case metrics.Counter:
+			families.add(name+"_total", "counter", "", labels, float64(m.Count()))
+		case metrics.Gauge:
+			families.add(name, "gauge", "", labels, float64(m.Value()))
+		case metrics.GaugeFloat64:
+			families.add(name, "gauge", "", labels, m.Value())
+		case metrics.Meter:
+			families.add(name+"_total", "counter", "", labels, float64(m.Snapshot().Count()))
+		case metrics.Histogram:
+			s := m.Snapshot()
+			families.addSummary(name, labels, s.Percentiles(prometheusQuantiles), float64(s.Sum()), s.Count(), 1)
+		case metrics.Timer:
+			s := m.Snapshot()
+			families.addSummary(name+"_seconds", labels, s.Percentiles(prometheusQuantiles), float64(s.Sum()), s.Count(), 1e-9)
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(families.Bytes())
+}
+
+var (
+	prometheusInvalid      = regexp.MustCompile("[^a-zA-Z0-9_:]+")
+	prometheusQuantiles    = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+	prometheusStatusSuffix = regexp.MustCompile(`-([1-5]xx|[1-5][0-9][0-9])$`)
+)
+
+// prometheusFamilies maps Prometheus metric names to their type and samples,
+// which must be written together.
+type prometheusFamilies map[string]*prometheusFamily
+
+type prometheusFamily struct {
+	typ     string
+	samples []string
+}
+
+// add adds a sample with the given name suffix and labels to a family,
+// creating it if necessary.  Samples whose type conflicts with their family's
+// are dropped since Prometheus would reject the whole response otherwise.
+func (families prometheusFamilies) add(name, typ, suffix, labels string, value float64) {
+	family, ok := families[name]
+	if !ok {
+		family = &prometheusFamily{typ: typ}
+		families[name] = family
+	}
+	if typ != family.typ {
+		return
+	}
+	if "" != labels {
+		labels = "{" + labels + "}"
+	}
+	family.samples = append(family.samples, name+suffix+labels+" "+prometheusFloat(value))
+}
+
+// addSummary adds the quantiles, sum, and count of a histogram or timer as
+// samples in a summary family, scaling values by the given factor.
+func (families prometheusFamilies) addSummary(name, labels string, ps []float64, sum float64, count int64, scale float64) {
+	for i, q := range prometheusQuantiles {
+		quantile := fmt.Sprintf("quantile=%q", strconv.FormatFloat(q, 'g', -1, 64))
+		if "" != labels {
+			quantile = labels + "," + quantile
+		}
+		families.add(name, "summary", "", quantile, ps[i]*scale)
+	}
+	families.add(name, "summary", "_sum", labels, sum*scale)
+	families.add(name, "summary", "_count", labels, float64(count))
+}
+
+// Bytes renders every family, ordered by name, in the Prometheus text
+// exposition format.
+func (families prometheusFamilies) Bytes() []byte {
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b := &bytes.Buffer{}
+	for _, name := range names {
+		fmt.Fprintf(b, "# TYPE %s %s\n", name, families[name].typ)
+		for _, sample := range families[name].samples {
+			b.WriteString(sample + "\n")
+		}
+	}
+	return b.Bytes()
+}
+
+// prometheusName turns a go-metrics name into a valid Prometheus metric name
+// by replacing runs of invalid characters with underscores.
+func prometheusName(namespace, name string) string {
+	if "" != namespace {
+		name = namespace + "_" + name
+	}
+	name = strings.Trim(prometheusInvalid.ReplaceAllString(name, "_"), "_")
+	if "" == name || '0' <= name[0] && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+func prometheusFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,38 @@
+package tigertonic
+
+import (
+	"github.com/rcrowley/go-metrics"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	registry := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter("http-2xx", registry).Inc(3)
+	metrics.GetOrRegisterCounter("http-5xx", registry).Inc(1)
+	metrics.GetOrRegisterGauge("goroutines", registry).Update(47)
+	metrics.GetOrRegisterMeter("requests", registry).Mark(2)
+	metrics.GetOrRegisterTimer("GET-stuff-id", registry).Update(2 * time.Second)
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/metrics", nil)
+	(&PrometheusHandler{Registry: registry, Namespace: "test"}).ServeHTTP(w, r)
+	if http.StatusOK != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "text/plain; version=0.0.4; charset=utf-8" != w.Header().Get("Content-Type") {
+		t.Fatal(w.Header().Get("Content-Type"))
+	}
+	for _, s := range []string{
+		"# TYPE test_GET_stuff_id_seconds summary\ntest_GET_stuff_id_seconds{quantile=\"0.5\"} 2\n",
+		"test_GET_stuff_id_seconds_sum 2\ntest_GET_stuff_id_seconds_count 1\n",
+		"# TYPE test_goroutines gauge\ntest_goroutines 47\n",
+		"# TYPE test_http_total counter\ntest_http_total{status=\"2xx\"} 3\ntest_http_total{status=\"5xx\"} 1\n",
+		"# TYPE test_requests_total counter\ntest_requests_total 2\n",
+	} {
+		if !strings.Contains(w.Body.String(), s) {
+			t.Errorf("%q not found in\n%s", s, w.Body.String())
+		}
+	}
+}
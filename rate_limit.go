@@ -0,0 +1,331 @@
+package tigertonic
+
+import (
+	"errors"
+	"github.com/rcrowley/go-metrics"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimiter is an http.Handler that limits how often each client, user, or
+// route may make requests.
+type RateLimiter struct {
+
+	// Store keeps each key's RateLimitState.  It defaults to a new
+	// MemoryRateLimitStore, which is fine unless several processes must
+	// share limits.
+	Store RateLimitStore
+
+	algorithm RateLimitAlgorithm
+	allowed   metrics.Counter
+	handler   http.Handler
+	key       RateLimitKeyFunc
+	name      string
+	rejected  metrics.Counter
+}
+
+// RateLimited returns an http.Handler that passes requests to an underlying
+// http.Handler unless the client has exceeded the limit given by the
+// algorithm, in which case it responds 429 via ResponseErrorWriter.  Requests
+// are limited separately by the key the given function returns for them;
+// requests for which it returns the empty string share one limit.
+// Every response says how much of the limit remains in the RateLimit-Limit,
+// RateLimit-Remaining, and RateLimit-Reset headers and 429 responses say when
+// to try again in the Retry-After header.  Allowed and rejected requests are
+// counted via go-metrics as "name-allowed" and "name-rejected".
+func RateLimited(
+	handler http.Handler,
+	key RateLimitKeyFunc,
+	algorithm RateLimitAlgorithm,
+	name string,
+	registry metrics.Registry,
+) *RateLimiter {
+	if nil == registry {
+		registry = metrics.DefaultRegistry
+	}
+	return &RateLimiter{
+		Store:     NewMemoryRateLimitStore(),
+		algorithm: algorithm,
+		allowed:   metrics.GetOrRegisterCounter(name+"-allowed", registry),
+		handler:   handler,
+		key:       key,
+		name:      name,
+		rejected:  metrics.GetOrRegisterCounter(name+"-rejected", registry),
+	}
+}
+
+// ServeHTTP takes from the limit for the request's key and passes the
+// request to the underlying http.Handler if it's allowed.
+func (rl *RateLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var decision RateLimitDecision
+	rl.Store.Update(rl.name+":"+rl.key(r), rl.algorithm.TTL(), func(state *RateLimitState) {
+		decision = rl.algorithm.Take(state, time.Now())
+	})
+	header := w.Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	header.Set("RateLimit-Reset", rateLimitSeconds(decision.Reset))
+	if !decision.Allowed {
+		rl.rejected.Inc(1)
+		header.Set("Retry-After", rateLimitSeconds(decision.RetryAfter))
+		ResponseErrorWriter.WriteError(r, w, TooManyRequests{errors.New("rate limit exceeded")})
+		return
+	}
+	rl.allowed.Inc(1)
+	rl.handler.ServeHTTP(w, r)
+}
+
+// rateLimitSeconds formats a duration as whole seconds, rounding up so
+// clients that wait that long aren't turned away again.
+func rateLimitSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// RateLimitKeyFunc chooses the key a request is limited by.
+type RateLimitKeyFunc func(*http.Request) string
+
+// RateLimitByRemoteAddr limits each client IP address separately.  Behind a
+// proxy, use RateLimitByHeader with whatever header it sets instead.
+func RateLimitByRemoteAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if nil != err {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RateLimitByUsername limits each authenticated user separately by the name
+// authentication middleware added to the request's context.Context: the
+// Principal's name from an AccessPolicy, the username HTTPBasicAuth verified,
+// or the subject of the claims BearerAuth verified.  RateLimited must be
+// nested inside that middleware.  Unauthenticated requests share one limit.
+func RateLimitByUsername(r *http.Request) string {
+	ctx := r.Context()
+	if p := PrincipalFromContext(ctx); nil != p && "" != p.Name {
+		return p.Name
+	}
+	if username := HTTPBasicAuthUsernameFromContext(ctx); "" != username {
+		return username
+	}
+	if claims := JWTClaimsFromContext(ctx); nil != claims {
+		return claims.Subject()
+	}
+	return ""
+}
+
+// RateLimitByHeader returns a RateLimitKeyFunc that limits each value of the
+// given header, such as an API key, separately.  Requests without it share
+// one limit.
+func RateLimitByHeader(name string) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// RateLimitByRoute returns a RateLimitKeyFunc that limits each HTTP method
+// and URL pattern in the given TrieServeMux separately.  Requests it doesn't
+// route anywhere share one limit.
+func RateLimitByRoute(mux *TrieServeMux) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+
+		// Routing rewrites the URL so route a copy, leaving the request
+		// as it was for the TrieServeMux to route again.
+		rCopy, u := *r, *r.URL
+		rCopy.URL = &u
+		_, handler, pattern := routeHandler(mux, &rCopy)
+		switch handler.(type) {
+		case MethodNotAllowedHandler, NotFoundHandler:
+			return ""
+		}
+		return r.Method + " " + pattern
+	}
+}
+
+// RateLimitByAll returns a RateLimitKeyFunc that combines the keys of all
+// the given functions, for example to limit each client on each route.
+func RateLimitByAll(keys ...RateLimitKeyFunc) RateLimitKeyFunc {
+	return func(r *http.Request) string {
+		components := make([]string, len(keys))
+		for i, key := range keys {
+			components[i] = key(r)
+		}
+		return strings.Join(components, "\x00")
+	}
+}
+
+// RateLimitAlgorithm decides whether a request is allowed given the state
+// kept for its key.
+type RateLimitAlgorithm interface {
+
+	// Take updates the state for a request at the given time and returns
+	// whether it's allowed.
+	Take(state *RateLimitState, now time.Time) RateLimitDecision
+
+	// TTL is how long state must be kept after its last update.
+	TTL() time.Duration
+}
+
+// RateLimitDecision is the outcome of RateLimitAlgorithm.Take.
+type RateLimitDecision struct {
+	Allowed          bool
+	Limit, Remaining int
+	Reset            time.Duration // until the limit is entirely available
+	RetryAfter       time.Duration // until the next request will be allowed
+}
+
+// RateLimitState is what a RateLimitStore keeps for each key.  Its meaning
+// depends on the RateLimitAlgorithm; its zero value means no requests.
+type RateLimitState struct {
+	Count, Previous float64
+	Time            time.Time
+}
+
+// TokenBucket allows bursts of up to Limit requests and refills at a steady
+// rate of Limit requests per Window.
+type TokenBucket struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Take spends a token, if there is one.  The state's Count is the number of
+// tokens spent as of its Time.
+func (tb TokenBucket) Take(state *RateLimitState, now time.Time) RateLimitDecision {
+	limit, rate := float64(tb.Limit), float64(tb.Limit)/tb.Window.Seconds()
+	if !state.Time.IsZero() {
+		state.Count = math.Max(0, state.Count-now.Sub(state.Time).Seconds()*rate)
+	}
+	state.Time = now
+	decision := RateLimitDecision{Limit: tb.Limit}
+	if state.Count+1 <= limit {
+		state.Count++
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = rateLimitDuration((state.Count + 1 - limit) / rate)
+	}
+	decision.Remaining = int(limit - state.Count)
+	decision.Reset = rateLimitDuration(state.Count / rate)
+	return decision
+}
+
+// TTL is the Window, after which the bucket is full again.
+func (tb TokenBucket) TTL() time.Duration {
+	return tb.Window
+}
+
+// SlidingWindow allows up to Limit requests in any Window, estimated from
+// the counts in the current and previous fixed windows.
+type SlidingWindow struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Take counts the request if the estimated count is below the limit.  The
+// state's Count and Previous are the counts in the window that began at its
+// Time and the one before it.
+func (sw SlidingWindow) Take(state *RateLimitState, now time.Time) RateLimitDecision {
+	limit := float64(sw.Limit)
+	start := now.Truncate(sw.Window)
+	if !start.Equal(state.Time) {
+		if start.Sub(state.Time) == sw.Window {
+			state.Previous = state.Count
+		} else {
+			state.Previous = 0
+		}
+		state.Count, state.Time = 0, start
+	}
+	elapsed := now.Sub(start)
+	weight := 1 - elapsed.Seconds()/sw.Window.Seconds()
+	estimate := state.Previous*weight + state.Count
+	decision := RateLimitDecision{Limit: sw.Limit, Reset: sw.Window - elapsed}
+	if estimate+1 <= limit {
+		state.Count++
+		estimate++
+		decision.Allowed = true
+	} else if state.Count+1 <= limit && 0 < state.Previous {
+
+		// Wait for enough of the previous window to slide out of this one.
+		decision.RetryAfter = rateLimitDuration(
+			(1-(limit-1-state.Count)/state.Previous)*sw.Window.Seconds(),
+		) - elapsed
+	} else {
+
+		// Wait for the next window and then for enough of this one to slide
+		// out of it.
+		decision.RetryAfter = sw.Window - elapsed
+		if 0 < state.Count {
+			decision.RetryAfter += rateLimitDuration(
+				math.Max(0, 1-(limit-1)/state.Count) * sw.Window.Seconds(),
+			)
+		}
+	}
+	decision.Remaining = int(math.Max(0, limit-estimate))
+	return decision
+}
+
+// TTL is two Windows, after which the previous window no longer matters.
+func (sw SlidingWindow) TTL() time.Duration {
+	return 2 * sw.Window
+}
+
+func rateLimitDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// RateLimitStore keeps the RateLimitState for each key.  Implementations may
+// keep it in memory or share it with other processes.
+type RateLimitStore interface {
+
+	// Update calls the given function with the state for the given key,
+	// which is the zero value if there isn't any, and keeps the changes it
+	// makes, atomically with respect to other updates to the same key.
+	// State that isn't updated again for the given TTL may be forgotten.
+	Update(key string, ttl time.Duration, f func(*RateLimitState))
+}
+
+// MemoryRateLimitStore is a RateLimitStore that keeps state in memory.
+type MemoryRateLimitStore struct {
+	mu     sync.Mutex
+	states map[string]*memoryRateLimitState
+	swept  time.Time
+}
+
+type memoryRateLimitState struct {
+	RateLimitState
+	expires time.Time
+}
+
+// NewMemoryRateLimitStore makes a new, empty MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		states: make(map[string]*memoryRateLimitState),
+		swept:  time.Now(),
+	}
+}
+
+// Update calls the given function with the state for the given key and
+// occasionally forgets state that has expired.
+func (s *MemoryRateLimitStore) Update(key string, ttl time.Duration, f func(*RateLimitState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.swept) > ttl {
+		for k, state := range s.states {
+			if now.After(state.expires) {
+				delete(s.states, k)
+			}
+		}
+		s.swept = now
+	}
+	state, ok := s.states[key]
+	if !ok || now.After(state.expires) {
+		state = &memoryRateLimitState{}
+		s.states[key] = state
+	}
+	f(&state.RateLimitState)
+	state.expires = now.Add(ttl)
+}
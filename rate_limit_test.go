@@ -0,0 +1,219 @@
+package tigertonic
+
+import (
+	"context"
+	"github.com/rcrowley/go-metrics"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	tb := TokenBucket{Limit: 2, Window: 2 * time.Second}
+	var state RateLimitState
+	now := time.Unix(1000, 0)
+	for i := 0; i < 2; i++ {
+		if d := tb.Take(&state, now); !d.Allowed || 1-i != d.Remaining {
+			t.Fatal(i, d)
+		}
+	}
+	d := tb.Take(&state, now)
+	if d.Allowed || 0 != d.Remaining || time.Second != d.RetryAfter || 2*time.Second != d.Reset {
+		t.Fatal(d)
+	}
+	if d := tb.Take(&state, now.Add(time.Second)); !d.Allowed || 0 != d.Remaining {
+		t.Fatal(d)
+	}
+	if d := tb.Take(&state, now.Add(5*time.Second)); !d.Allowed || 1 != d.Remaining {
+		t.Fatal(d)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	sw := SlidingWindow{Limit: 4, Window: 10 * time.Second}
+	var state RateLimitState
+	now := time.Unix(1000, 0)
+	for i := 0; i < 4; i++ {
+		if d := sw.Take(&state, now); !d.Allowed || 3-i != d.Remaining {
+			t.Fatal(i, d)
+		}
+	}
+	d := sw.Take(&state, now.Add(5*time.Second))
+	if d.Allowed || 0 != d.Remaining || 5*time.Second != d.Reset || 7500*time.Millisecond != d.RetryAfter {
+		t.Fatal(d)
+	}
+
+	// Halfway through the next window, half of the previous one counts.
+	if d := sw.Take(&state, now.Add(15*time.Second)); !d.Allowed || 1 != d.Remaining {
+		t.Fatal(d)
+	}
+	d = sw.Take(&state, now.Add(15*time.Second))
+	if !d.Allowed || 0 != d.Remaining {
+		t.Fatal(d)
+	}
+	d = sw.Take(&state, now.Add(15*time.Second))
+	if d.Allowed || 2500*time.Millisecond != d.RetryAfter {
+		t.Fatal(d)
+	}
+
+	// Two windows later, nothing counts.
+	if d := sw.Take(&state, now.Add(30*time.Second)); !d.Allowed || 3 != d.Remaining {
+		t.Fatal(d)
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	registry := metrics.NewRegistry()
+	rl := RateLimited(
+		NotFoundHandler{},
+		RateLimitByRemoteAddr,
+		TokenBucket{Limit: 1, Window: time.Minute},
+		"test",
+		registry,
+	)
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	r.RemoteAddr = "127.0.0.1:48879"
+	w := &testResponseWriter{}
+	rl.ServeHTTP(w, r)
+	if http.StatusNotFound != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "1" != w.Header().Get("RateLimit-Limit") || "0" != w.Header().Get("RateLimit-Remaining") || "60" != w.Header().Get("RateLimit-Reset") {
+		t.Fatal(w.Header())
+	}
+	r.RemoteAddr = "127.0.0.1:48880"
+	w = &testResponseWriter{}
+	rl.ServeHTTP(w, r)
+	if http.StatusTooManyRequests != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); "59" != retryAfter && "60" != retryAfter {
+		t.Fatal(w.Header())
+	}
+	r.RemoteAddr = "127.0.0.2:48879"
+	w = &testResponseWriter{}
+	rl.ServeHTTP(w, r)
+	if http.StatusNotFound != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if c := registry.Get("test-allowed").(metrics.Counter).Count(); 2 != c {
+		t.Fatal(c)
+	}
+	if c := registry.Get("test-rejected").(metrics.Counter).Count(); 1 != c {
+		t.Fatal(c)
+	}
+}
+
+func TestRateLimitedWithoutKey(t *testing.T) {
+	rl := RateLimited(
+		NotFoundHandler{},
+		RateLimitByUsername,
+		TokenBucket{Limit: 1, Window: time.Minute},
+		"test",
+		metrics.NewRegistry(),
+	)
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	w := &testResponseWriter{}
+	rl.ServeHTTP(w, r)
+	if http.StatusNotFound != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+
+	// An unverified username is just another unauthenticated request.
+	r.SetBasicAuth("rcrowley", "wrong-password")
+	w = &testResponseWriter{}
+	rl.ServeHTTP(w, r)
+	if http.StatusTooManyRequests != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+}
+
+func TestRateLimitedSameName(t *testing.T) {
+	registry := metrics.NewRegistry()
+	algorithm := TokenBucket{Limit: 1, Window: time.Minute}
+	RateLimited(NotFoundHandler{}, RateLimitByRemoteAddr, algorithm, "test", registry)
+	rl := RateLimited(NotFoundHandler{}, RateLimitByRemoteAddr, algorithm, "test", registry)
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	rl.ServeHTTP(&testResponseWriter{}, r)
+	if c := registry.Get("test-allowed").(metrics.Counter).Count(); 1 != c {
+		t.Fatal(c)
+	}
+}
+
+func TestRateLimitedByRouteNamespace(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.Handle("GET", "/stuff/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if "1" != r.URL.Query().Get("id") {
+			t.Fatal(r.URL)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	nsMux := NewTrieServeMux()
+	nsMux.HandleNamespace("/1.0", mux)
+	rl := RateLimited(
+		nsMux,
+		RateLimitByRoute(nsMux),
+		TokenBucket{Limit: 10, Window: time.Minute},
+		"test",
+		metrics.NewRegistry(),
+	)
+	r, _ := http.NewRequest("GET", "http://example.com/1.0/stuff/1", nil)
+	w := &testResponseWriter{}
+	rl.ServeHTTP(w, r)
+	if http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "9" != w.Header().Get("RateLimit-Remaining") {
+		t.Fatal(w.Header())
+	}
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.Handle("GET", "/stuff/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r, _ := http.NewRequest("GET", "http://example.com/stuff/1", nil)
+	r.RemoteAddr = "127.0.0.1:48879"
+	r = r.WithContext(context.WithValue(r.Context(), httpBasicAuthUsernameKey{}, "rcrowley"))
+	r.Header.Set("X-API-Key", "foo")
+	if key := RateLimitByRemoteAddr(r); "127.0.0.1" != key {
+		t.Fatal(key)
+	}
+	if key := RateLimitByUsername(r); "rcrowley" != key {
+		t.Fatal(key)
+	}
+	if key := RateLimitByHeader("X-API-Key")(r); "foo" != key {
+		t.Fatal(key)
+	}
+	if key := RateLimitByRoute(mux)(r); "GET /stuff/{id}" != key {
+		t.Fatal(key)
+	}
+	if key := RateLimitByAll(RateLimitByUsername, RateLimitByRoute(mux))(r); "rcrowley\x00GET /stuff/{id}" != key {
+		t.Fatal(key)
+	}
+	r, _ = http.NewRequest("GET", "http://example.com/things", nil)
+	r.SetBasicAuth("rcrowley", "password")
+	if key := RateLimitByRoute(mux)(r); "" != key {
+		t.Fatal(key)
+	}
+	if key := RateLimitByUsername(r); "" != key {
+		t.Fatal(key)
+	}
+	r = r.WithContext(context.WithValue(r.Context(), jwtClaimsKey{}, JWTClaims{"sub": "rcrowley"}))
+	if key := RateLimitByUsername(r); "rcrowley" != key {
+		t.Fatal(key)
+	}
+}
+
+func TestMemoryRateLimitStoreExpires(t *testing.T) {
+	s := NewMemoryRateLimitStore()
+	s.Update("foo", time.Millisecond, func(state *RateLimitState) { state.Count = 1 })
+	time.Sleep(2 * time.Millisecond)
+	s.Update("foo", time.Millisecond, func(state *RateLimitState) {
+		if 0 != state.Count {
+			t.Fatal(state.Count)
+		}
+	})
+	if 1 != len(s.states) {
+		t.Fatal(s.states)
+	}
+}
@@ -0,0 +1,111 @@
+package tigertonic
+
+import (
+	"fmt"
+	"github.com/rcrowley/go-metrics"
+	"log"
+	"net/http"
+	"os"
+	"runtime/debug"
+)
+
+// Recoverer is an http.Handler that turns panics into 500 responses.
+type Recoverer struct {
+
+	// Counter is incremented for each panic.  It defaults to the panic
+	// counter in metrics.DefaultRegistry.
+	Counter metrics.Counter
+
+	// Logger logs each panic with its stack trace.  It defaults to standard
+	// error.
+	Logger Logger
+
+	// Repanic, if true, panics again after logging and counting so tests
+	// fail loudly instead of seeing a 500.
+	Repanic bool
+
+	// RequestIDCreator gives the request a RequestID if a logger hasn't
+	// already.
+	RequestIDCreator RequestIDCreator
+
+	handler http.Handler
+}
+
+// Recovered returns an http.Handler that passes requests to an underlying
+// http.Handler and recovers if it panics.  The panic and its stack trace are
+// logged with the request's RequestID and, if the underlying http.Handler
+// hasn't written a status yet, the client gets a 500 with the RequestID via
+// ResponseErrorWriter instead of a dropped connection.  Headers the
+// underlying http.Handler set before panicking are discarded.  If it already
+// wrote a status, the truncated response can't be fixed so Recoverer panics
+// with http.ErrAbortHandler to make net/http abort the connection.
+func Recovered(handler http.Handler) *Recoverer {
+	return &Recoverer{
+		Counter:          metrics.GetOrRegisterCounter("panic", nil),
+		Logger:           log.New(os.Stderr, "", log.LstdFlags),
+		RequestIDCreator: requestIDCreator,
+		handler:          handler,
+	}
+}
+
+// ServeHTTP passes the request to the underlying http.Handler and recovers
+// if it panics.
+func (rec *Recoverer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := rec.RequestIDCreator(r)
+	r = withRequestID(r, requestID)
+	rw := &recovererResponseWriter{ResponseWriter: w}
+	defer func() {
+		err := recover()
+		if nil == err {
+			return
+		}
+		if http.ErrAbortHandler == err {
+			panic(err)
+		}
+		rec.Counter.Inc(1)
+		rec.Logger.Printf(
+			"%s panic serving %s %s: %v\n%s",
+			requestID,
+			r.Method,
+			r.URL.RequestURI(),
+			err,
+			debug.Stack(),
+		)
+		if rec.Repanic {
+			panic(err)
+		}
+		if rw.wroteHeader {
+			panic(http.ErrAbortHandler)
+		}
+		for k := range w.Header() {
+			delete(w.Header(), k)
+		}
+		w.Header().Set("X-Request-ID", string(requestID))
+		ResponseErrorWriter.WriteError(r, w, InternalServerError{
+			fmt.Errorf("internal server error, request ID %s", requestID),
+		})
+	}()
+	rec.handler.ServeHTTP(rw, r)
+}
+
+type recovererResponseWriter struct {
+	http.Flusher
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *recovererResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *recovererResponseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *recovererResponseWriter) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
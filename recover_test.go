@@ -0,0 +1,130 @@
+package tigertonic
+
+import (
+	"bytes"
+	"github.com/rcrowley/go-metrics"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRecovered(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	r.Header.Set("Accept", "application/json")
+	rec := testRecovered(Marshaled(func(u *url.URL, h http.Header, _ interface{}) (int, http.Header, interface{}, error) {
+		panic("foo")
+	}))
+	b := rec.Logger.(*log.Logger).Writer().(*bytes.Buffer)
+	rec.ServeHTTP(w, r)
+	if http.StatusInternalServerError != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	requestID := w.Header().Get("X-Request-ID")
+	if 16 != len(requestID) {
+		t.Fatal(requestID)
+	}
+	if !strings.Contains(w.Body.String(), `"description":"internal server error, request ID `+requestID+`"`) {
+		t.Fatal(w.Body.String())
+	}
+	if 1 != rec.Counter.Count() {
+		t.Fatal(rec.Counter.Count())
+	}
+	if s := b.String(); !strings.HasPrefix(s, requestID+" panic serving GET /foo: foo\n") || !strings.Contains(s, "goroutine") {
+		t.Fatal(s)
+	}
+}
+
+func TestRecoveredLogged(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	logger := JSONLogged(testRecovered(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("foo")
+	})), nil)
+	b := &bytes.Buffer{}
+	logger.Logger = log.New(b, "", 0)
+	logger.ServeHTTP(w, r)
+	if http.StatusInternalServerError != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	requestID := w.Header().Get("X-Request-ID")
+	if s := b.String(); !strings.Contains(s, `"@request_id":"`+requestID+`"`) || !strings.Contains(s, `"status":500`) {
+		t.Fatal(s)
+	}
+}
+
+func TestRecoveredAfterWriteHeader(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	rec := testRecovered(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("foo")
+	}))
+	defer func() {
+		if err := recover(); http.ErrAbortHandler != err {
+			t.Fatal(err)
+		}
+		if http.StatusAccepted != w.StatusCode {
+			t.Fatal(w.StatusCode)
+		}
+		if 0 != w.Body.Len() {
+			t.Fatal(w.Body.String())
+		}
+		if 1 != rec.Counter.Count() {
+			t.Fatal(rec.Counter.Count())
+		}
+	}()
+	rec.ServeHTTP(w, r)
+	t.Fatal("didn't panic")
+}
+
+func TestRecoveredDiscardsHeaders(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	r.Header.Set("Accept", "application/json")
+	rec := testRecovered(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Location", "http://example.com/bar")
+		panic("foo")
+	}))
+	rec.ServeHTTP(w, r)
+	if http.StatusInternalServerError != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "" != w.Header().Get("Location") {
+		t.Fatal(w.Header())
+	}
+	if "application/json" != w.Header().Get("Content-Type") {
+		t.Fatal(w.Header())
+	}
+	if 16 != len(w.Header().Get("X-Request-ID")) {
+		t.Fatal(w.Header())
+	}
+}
+
+func TestRecoveredRepanic(t *testing.T) {
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	rec := testRecovered(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("foo")
+	}))
+	rec.Repanic = true
+	defer func() {
+		if err := recover(); "foo" != err {
+			t.Fatal(err)
+		}
+		if 1 != rec.Counter.Count() {
+			t.Fatal(rec.Counter.Count())
+		}
+	}()
+	rec.ServeHTTP(&testResponseWriter{}, r)
+	t.Fatal("didn't panic")
+}
+
+func testRecovered(handler http.Handler) *Recoverer {
+	rec := Recovered(handler)
+	rec.Counter = metrics.NewCounter()
+	rec.Logger = log.New(&bytes.Buffer{}, "", 0)
+	return rec
+}
@@ -1,29 +1,33 @@
 package tigertonic
 
 import (
+	"context"
 	"crypto/tls"
-	"crypto/x509"
-	"io/ioutil"
+	"fmt"
 	"net"
 	"net/http"
+	"os"
 	"sync"
-	"time"
+	"sync/atomic"
 )
 
 // Server is an http.Server with better defaults and built-in graceful stop.
 type Server struct {
 	http.Server
-	ch        chan<- struct{}
-	conns     map[string]net.Conn
-	listeners []net.Listener
-	mu        sync.Mutex // guards conns and listeners
-	wg        sync.WaitGroup
+	ch           chan struct{}
+	conns        map[net.Conn]http.ConnState
+	listeners    []net.Listener
+	mu           sync.Mutex // guards conns, listeners, hooks, and tlsFiles
+	once         sync.Once
+	postShutdown []func(int)
+	preShutdown  []func()
+	tlsFiles     tlsFiles
+	tlsReloaded  atomic.Value // *tlsReloaded
 }
 
 // NewServer returns an http.Server with better defaults and built-in graceful
 // stop.
 func NewServer(addr string, handler http.Handler) *Server {
-	ch := make(chan struct{})
 	s := &Server{
 		Server: http.Server{
 			Addr: addr,
@@ -34,36 +38,25 @@ func NewServer(addr string, handler http.Handler) *Server {
 			ReadTimeout:    60e9, // These are absolute times which must be
 			WriteTimeout:   60e9, // longer than the longest {up,down}load.
 		},
-		ch:    ch,
-		conns: make(map[string]net.Conn),
+		ch:    make(chan struct{}),
+		conns: make(map[net.Conn]http.ConnState),
 	}
 	s.ConnState = func(conn net.Conn, state http.ConnState) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
 		switch state {
-		case http.StateNew:
-			s.wg.Add(1)
-		case http.StateActive:
-			s.mu.Lock()
-			delete(s.conns, conn.LocalAddr().String())
-			s.mu.Unlock()
-		case http.StateIdle:
-			select {
-			case <-ch:
-				//conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond)) // Doesn't work but seems like the right idea.
-				conn.Close()
-			default:
-				s.mu.Lock()
-				s.conns[conn.LocalAddr().String()] = conn
-				s.mu.Unlock()
-			}
+		case http.StateNew, http.StateActive, http.StateIdle:
+			s.conns[conn] = state
 		case http.StateHijacked, http.StateClosed:
-			s.wg.Done()
+			delete(s.conns, conn)
 		}
 	}
 	return s
 }
 
 // NewTLSServer returns an http.Server with better defaults configured to use
-// the certificate and private key files.
+// the certificate and private key files.  It allows TLS 1.2 and newer with
+// the TLSIntermediate profile's cipher suites and negotiates HTTP/2.
 func NewTLSServer(
 	addr, cert, key string,
 	handler http.Handler,
@@ -74,57 +67,96 @@ func NewTLSServer(
 
 // CA overrides the certificate authority on the Server's TLSConfig field.
 func (s *Server) CA(ca string) error {
-	certPool := x509.NewCertPool()
-	buf, err := ioutil.ReadFile(ca)
+	certPool, err := loadCertPool(ca)
 	if nil != err {
 		return err
 	}
-	certPool.AppendCertsFromPEM(buf)
 	s.tlsConfig()
 	s.TLSConfig.RootCAs = certPool
+	s.mu.Lock()
+	s.tlsFiles.ca = ca
+	s.forgetReloadedTLS(func(reloaded *tlsReloaded) { reloaded.rootCAs = nil })
+	s.mu.Unlock()
 	return nil
 }
 
 // ClientCA configures the CA pool for verifying client side certificates.
 func (s *Server) ClientCA(ca string) error {
-	certPool := x509.NewCertPool()
-	buf, err := ioutil.ReadFile(ca)
+	certPool, err := loadCertPool(ca)
 	if nil != err {
 		return err
 	}
-	certPool.AppendCertsFromPEM(buf)
 	s.tlsConfig()
 	s.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
 	s.TLSConfig.ClientCAs = certPool
+	s.mu.Lock()
+	s.tlsFiles.clientCA = ca
+	s.forgetReloadedTLS(func(reloaded *tlsReloaded) { reloaded.clientCAs = nil })
+	s.mu.Unlock()
 	return nil
 }
 
 // Close closes all the net.Listeners passed to Serve (even via ListenAndServe)
 // and signals open connections to close at their earliest convenience.  That
-// is either after responding to the current request or after a short grace
-// period for idle keepalive connections.  Close blocks until all connections
-// have been closed.
+// is either after responding to the current request or immediately for idle
+// keepalive connections.  Close blocks until all connections have been
+// closed.
 func (s *Server) Close() error {
-	close(s.ch)
-	s.SetKeepAlivesEnabled(false)
+	return s.Shutdown(context.Background())
+}
+
+// RegisterPostShutdown registers a function to be called once Shutdown has
+// drained or forcibly closed every connection.  It's passed the number of
+// connections that were forcibly closed.
+func (s *Server) RegisterPostShutdown(f func(forced int)) {
 	s.mu.Lock()
-	for _, l := range s.listeners {
-		if err := l.Close(); nil != err {
-			return err
-		}
+	defer s.mu.Unlock()
+	s.postShutdown = append(s.postShutdown, f)
+}
+
+// RegisterPreShutdown registers a function to be called when Shutdown is
+// called, before the Server stops accepting connections.
+func (s *Server) RegisterPreShutdown(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.preShutdown = append(s.preShutdown, f)
+}
+
+// Shutdown stops the Server gracefully like Close but gives up waiting when
+// the context is done, forcibly closing the connections that remain and
+// returning the context's error.  The graceful part is http.Server's
+// Shutdown so HTTP/2 clients are sent GOAWAY.  Functions registered with
+// RegisterPostShutdown are passed the number of connections that were
+// forcibly closed.  Handlers still running on forcibly closed connections
+// are not interrupted, though their writes will fail.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	preShutdown := s.preShutdown
+	s.mu.Unlock()
+	for _, f := range preShutdown {
+		f()
 	}
+	s.once.Do(func() { close(s.ch) })
+	err := s.Server.Shutdown(ctx)
+	var forced int
+	s.mu.Lock()
 	s.listeners = nil
-	t := time.Now().Add(500 * time.Millisecond)
-	for _, c := range s.conns {
-		c.SetReadDeadline(t)
+	if nil != err && nil != ctx.Err() {
+		for c := range s.conns {
+			c.Close()
+			forced++
+		}
 	}
-	s.conns = make(map[string]net.Conn)
+	postShutdown := s.postShutdown
 	s.mu.Unlock()
-	s.wg.Wait()
-	return nil
+	for _, f := range postShutdown {
+		f(forced)
+	}
+	return err
 }
 
-// ListenAndServe calls net.Listen with s.Addr and then calls s.Serve.
+// ListenAndServe calls net.Listen with s.Addr, unless a listener bound to
+// s.Addr was inherited from a parent process, and then calls s.Serve.
 func (s *Server) ListenAndServe() error {
 	addr := s.Addr
 	if "" == addr {
@@ -134,12 +166,17 @@ func (s *Server) ListenAndServe() error {
 			addr = ":https"
 		}
 	}
-	l, err := net.Listen("tcp", addr)
+	l, err := inheritedListener(addr)
 	if nil != err {
 		return err
 	}
+	if nil == l {
+		if l, err = net.Listen("tcp", addr); nil != err {
+			return err
+		}
+	}
 	if nil != s.TLSConfig {
-		l = tls.NewListener(l, s.TLSConfig)
+		l = &tlsListener{tls.NewListener(l, s.TLSConfig), l}
 	}
 	return s.Serve(l)
 }
@@ -171,15 +208,35 @@ func (s *Server) TLS(cert, key string) error {
 	}
 	s.tlsConfig()
 	s.TLSConfig.Certificates = []tls.Certificate{c}
+	s.mu.Lock()
+	s.tlsFiles.cert, s.tlsFiles.key = cert, key
+	s.forgetReloadedTLS(func(reloaded *tlsReloaded) { reloaded.certificates = nil })
+	s.mu.Unlock()
 	return nil
 }
 
 func (s *Server) tlsConfig() {
 	if nil == s.TLSConfig {
-		s.TLSConfig = &tls.Config{
-			NextProtos: []string{"http/1.1"},
-		}
+		s.TLSConfig = NewTLSConfig(TLSIntermediate)
+		s.TLSConfig.GetConfigForClient = s.getConfigForClient
+	}
+}
+
+// tlsListener is a TLS net.Listener whose underlying socket may be passed to
+// a child process by Server.Reexec.
+type tlsListener struct {
+	net.Listener
+	raw net.Listener
+}
+
+func (l *tlsListener) File() (*os.File, error) {
+	filer, ok := l.raw.(interface {
+		File() (*os.File, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("%T listening on %s can't be passed to a child process", l.raw, l.Addr())
 	}
+	return filer.File()
 }
 
 type serverHandler struct {
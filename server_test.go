@@ -1,6 +1,8 @@
 package tigertonic
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
@@ -23,10 +25,11 @@ func TestServerCATLS(t *testing.T) {
 }
 
 func TestServerGracefulStop(t *testing.T) {
-	chT := make(chan time.Time, 1)
+	chStarted, chT := make(chan struct{}), make(chan time.Time, 1)
 	s := NewServer(
 		"127.0.0.1:0",
 		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			close(chStarted)
 			time.Sleep(2 * time.Millisecond)
 			w.WriteHeader(http.StatusNoContent)
 			chT <- time.Now()
@@ -45,7 +48,10 @@ func TestServerGracefulStop(t *testing.T) {
 		}
 		chR <- rs
 	}()
-	time.Sleep(time.Millisecond)
+
+	// Requests that haven't been read when the Server begins to stop are
+	// dropped so wait until this one is being handled.
+	<-chStarted
 	s.Close()
 	now := time.Now()
 	then := <-chT
@@ -126,3 +132,90 @@ func TestServerGracefulStopMulti(t *testing.T) {
 		t.Fatal("GET / should have failed after server stopped")
 	}
 }
+
+func TestServerShutdownDeadline(t *testing.T) {
+	chStuck, chDone := make(chan struct{}), make(chan struct{})
+	s := NewServer(
+		"127.0.0.1:0",
+		http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			chStuck <- struct{}{}
+			<-chDone
+		}),
+	)
+	defer close(chDone)
+	var pre, post int
+	s.RegisterPreShutdown(func() { pre++ })
+	s.RegisterPostShutdown(func(forced int) { post = forced })
+	l, err := net.Listen("tcp", s.Addr)
+	if nil != err {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	chErr := make(chan error, 1)
+	go func() {
+		_, err := http.Get(fmt.Sprintf("http://%s", l.Addr()))
+		chErr <- err
+	}()
+	<-chStuck
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); context.DeadlineExceeded != err {
+		t.Fatal(err)
+	}
+	if 1 != pre || 1 != post {
+		t.Fatal(pre, post)
+	}
+	if err := <-chErr; nil == err {
+		t.Fatal("GET / should have failed after its connection was closed")
+	}
+}
+
+func TestServerShutdownSignature(t *testing.T) {
+	var _ interface {
+		Shutdown(context.Context) error
+	} = NewServer("", NotFoundHandler{})
+}
+
+func TestServerHTTP2(t *testing.T) {
+	s, err := NewTLSServer("127.0.0.1:0", "test.crt", "test.key", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if 2 != r.ProtoMajor || "https" != r.URL.Scheme || "" == r.URL.Host {
+			t.Error(r.Proto, r.URL)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if tls.VersionTLS12 != s.TLSConfig.MinVersion || "h2" != s.TLSConfig.NextProtos[0] {
+		t.Fatal(s.TLSConfig)
+	}
+	l, err := net.Listen("tcp", s.Addr)
+	if nil != err {
+		t.Fatal(err)
+	}
+	go s.Serve(tls.NewListener(l, s.TLSConfig))
+	defer s.Close()
+	client := &http.Client{Transport: &http.Transport{
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+	}}
+	rs, err := client.Get(fmt.Sprintf("https://%s", l.Addr()))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if http.StatusNoContent != rs.StatusCode || 2 != rs.ProtoMajor {
+		t.Fatal(rs.StatusCode, rs.Proto)
+	}
+}
+
+func TestServerTLSProfile(t *testing.T) {
+	s := NewServer("", NotFoundHandler{})
+	s.TLSProfile(TLSModern)
+	if tls.VersionTLS13 != s.TLSConfig.MinVersion || nil != s.TLSConfig.CipherSuites {
+		t.Fatal(s.TLSConfig)
+	}
+	s.TLSProfile(TLSOld)
+	if tls.VersionTLS10 != s.TLSConfig.MinVersion || tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 != s.TLSConfig.CipherSuites[0] {
+		t.Fatal(s.TLSConfig)
+	}
+}
@@ -0,0 +1,171 @@
+package tigertonic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TimeoutHandler is an http.Handler that gives each request a deadline.
+type TimeoutHandler struct {
+
+	// Err is the error written via ResponseErrorWriter when the deadline
+	// passes before the underlying http.Handler has written a status.  It
+	// defaults to a ServiceUnavailable; use a GatewayTimeout for handlers
+	// that are waiting on another service.
+	Err error
+
+	handler http.Handler
+	timeout time.Duration
+}
+
+// TimedOut returns an http.Handler that passes requests to an underlying
+// http.Handler with a context.Context that's done after the given duration.
+// If the underlying http.Handler hasn't written a status by then, respond
+// 503 and discard anything it writes afterward.  Marshaled functions that
+// take a context.Context get the one with the deadline.
+func TimedOut(handler http.Handler, timeout time.Duration) *TimeoutHandler {
+	return &TimeoutHandler{
+		Err:     ServiceUnavailable{errors.New("request timed out")},
+		handler: handler,
+		timeout: timeout,
+	}
+}
+
+// ServeHTTP passes the request to the underlying http.Handler and responds
+// with an error if it takes too long.  Once the underlying http.Handler has
+// written a status before the deadline, there's no way to take it back so
+// ServeHTTP waits for it to finish as usual.  Anything it writes after the
+// deadline, even if it returns before ServeHTTP notices, is discarded in
+// favor of the error.
+func (h *TimeoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+	tw := &timeoutResponseWriter{ctx: ctx, header: make(http.Header), w: w}
+	done := make(chan struct{})
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if err := recover(); nil != err {
+				panicked <- err
+				return
+			}
+			close(done)
+		}()
+		h.handler.ServeHTTP(tw, r.WithContext(ctx))
+	}()
+	select {
+	case <-done:
+		if !tw.finish() {
+			ResponseErrorWriter.WriteError(r, w, h.Err)
+		}
+		return
+	case err := <-panicked:
+		panic(err)
+	case <-ctx.Done():
+	}
+	tw.mu.Lock()
+	if tw.wroteHeader {
+		tw.mu.Unlock()
+		select {
+		case <-done:
+		case err := <-panicked:
+			panic(err)
+		}
+		return
+	}
+	tw.timedOut = true
+	tw.mu.Unlock()
+	ResponseErrorWriter.WriteError(r, w, h.Err)
+}
+
+// timeoutResponseWriter passes writes through to an http.ResponseWriter
+// until the TimeoutHandler it belongs to has responded with an error, after
+// which it discards them.  It keeps its own headers so the underlying
+// http.ResponseWriter's aren't touched concurrently.
+type timeoutResponseWriter struct {
+	ctx         context.Context
+	header      http.Header
+	mu          sync.Mutex
+	timedOut    bool
+	w           http.ResponseWriter
+	wroteHeader bool
+}
+
+func (tw *timeoutResponseWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (tw *timeoutResponseWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutResponseWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.wroteHeader {
+		tw.expire()
+	}
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+	return tw.w.Write(p)
+}
+
+func (tw *timeoutResponseWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.wroteHeader {
+		tw.expire()
+	}
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.writeHeader(code)
+}
+
+// expire marks the response as timed out if the deadline has passed, so a
+// status written in reaction to the deadline can't beat the error to the
+// client.  The caller must hold the mutex.
+func (tw *timeoutResponseWriter) expire() {
+	if context.DeadlineExceeded == tw.ctx.Err() {
+		tw.timedOut = true
+	}
+}
+
+// finish writes the status if the underlying http.Handler returned without
+// writing anything, so its headers aren't lost.  It returns false if the
+// response timed out instead, in which case the error must be written.
+func (tw *timeoutResponseWriter) finish() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.wroteHeader {
+		tw.expire()
+		if tw.timedOut {
+			return false
+		}
+		tw.writeHeader(http.StatusOK)
+	}
+	return true
+}
+
+func (tw *timeoutResponseWriter) writeHeader(code int) {
+	header := tw.w.Header()
+	for name, values := range tw.header {
+		header[name] = values
+	}
+	tw.w.WriteHeader(code)
+	tw.wroteHeader = true
+}
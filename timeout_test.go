@@ -0,0 +1,118 @@
+package tigertonic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimedOut(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	TimedOut(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Foo", "bar")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("foo\n"))
+	}), time.Second).ServeHTTP(w, r)
+	if http.StatusAccepted != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "bar" != w.Header().Get("X-Foo") {
+		t.Fatal(w.Header())
+	}
+	if "foo\n" != w.Body.String() {
+		t.Fatal(w.Body.String())
+	}
+}
+
+func TestTimedOutNoWrite(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	TimedOut(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Foo", "bar")
+	}), time.Second).ServeHTTP(w, r)
+	if http.StatusOK != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "bar" != w.Header().Get("X-Foo") {
+		t.Fatal(w.Header())
+	}
+}
+
+func TestTimedOutDeadline(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	r.Header.Set("Accept", "text/plain")
+	ch, release := make(chan error), make(chan struct{})
+	TimedOut(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		<-release
+		w.Header().Set("X-Foo", "bar")
+		_, err := w.Write([]byte("late\n"))
+		ch <- err
+	}), time.Millisecond).ServeHTTP(w, r)
+	close(release)
+	if err := <-ch; http.ErrHandlerTimeout != err {
+		t.Fatal(err)
+	}
+	if http.StatusServiceUnavailable != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "text/plain" != w.Header().Get("Content-Type") || "" != w.Header().Get("X-Foo") {
+		t.Fatal(w.Header())
+	}
+	if "tigertonic.ServiceUnavailable: request timed out" != w.Body.String() {
+		t.Fatal(w.Body.String())
+	}
+}
+
+func TestTimedOutMarshaled(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	r.Header.Set("Accept", "application/json")
+	h := TimedOut(Marshaled(func(ctx context.Context, u *url.URL, h http.Header, _ interface{}) (int, http.Header, interface{}, error) {
+		<-ctx.Done()
+		return 0, nil, nil, ctx.Err()
+	}), time.Millisecond)
+	h.Err = GatewayTimeout{errors.New("upstream timed out")}
+	h.ServeHTTP(w, r)
+	if http.StatusGatewayTimeout != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if !strings.Contains(w.Body.String(), `"error":"tigertonic.GatewayTimeout"`) {
+		t.Fatal(w.Body.String())
+	}
+}
+
+func TestTimedOutAfterWriteHeader(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	TimedOut(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		<-r.Context().Done()
+		w.Write([]byte("foo\n"))
+	}), time.Millisecond).ServeHTTP(w, r)
+	if http.StatusOK != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "foo\n" != w.Body.String() {
+		t.Fatal(w.Body.String())
+	}
+}
+
+func TestTimedOutPanic(t *testing.T) {
+	defer func() {
+		if err := recover(); "foo" != err {
+			t.Fatal(err)
+		}
+	}()
+	r, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	TimedOut(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("foo")
+	}), time.Second).ServeHTTP(&testResponseWriter{}, r)
+	t.Fatal("didn't panic")
+}
@@ -0,0 +1,78 @@
+package tigertonic
+
+import "crypto/tls"
+
+// TLSProfile chooses TLS versions and cipher suites after Mozilla's server
+// side TLS recommendations, trading compatibility with old clients for
+// security.
+type TLSProfile int
+
+const (
+	// TLSIntermediate allows TLS 1.2 and newer with forward-secret AEAD
+	// cipher suites.  It's the default.
+	TLSIntermediate TLSProfile = iota
+
+	// TLSModern allows only TLS 1.3.
+	TLSModern
+
+	// TLSOld allows TLS 1.0 and newer with CBC cipher suites for clients
+	// that can't do better.  Use it only if you must.
+	TLSOld
+)
+
+// NewTLSConfig returns a tls.Config following the given profile that
+// negotiates HTTP/2 as well as HTTP/1.1.
+func NewTLSConfig(profile TLSProfile) *tls.Config {
+	config := &tls.Config{
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+		NextProtos:       []string{"h2", "http/1.1"},
+	}
+	profile.apply(config)
+	return config
+}
+
+// TLSProfile changes the TLS versions and cipher suites the Server allows to
+// those of the given profile.
+func (s *Server) TLSProfile(profile TLSProfile) {
+	s.tlsConfig()
+	profile.apply(s.TLSConfig)
+}
+
+func (profile TLSProfile) apply(config *tls.Config) {
+	switch profile {
+	case TLSModern:
+		config.MinVersion, config.CipherSuites = tls.VersionTLS13, nil
+	case TLSOld:
+		config.MinVersion = tls.VersionTLS10
+		config.CipherSuites = append(append([]uint16(nil), tlsIntermediateCipherSuites...), tlsOldCipherSuites...)
+	default:
+		config.MinVersion = tls.VersionTLS12
+		config.CipherSuites = append([]uint16(nil), tlsIntermediateCipherSuites...)
+	}
+}
+
+// HTTP/2 requires that the cipher suites it approves of, which are all here,
+// come before any others.
+var tlsIntermediateCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+}
+
+var tlsOldCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+}
@@ -0,0 +1,203 @@
+package tigertonic
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/rcrowley/go-metrics"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// tlsFiles are the pathnames passed to Server.TLS, CA, and ClientCA, which
+// are read again by Server.ReloadTLS.
+type tlsFiles struct {
+	cert, key, ca, clientCA string
+}
+
+func (files tlsFiles) pathnames() []string {
+	var pathnames []string
+	for _, pathname := range []string{files.cert, files.key, files.ca, files.clientCA} {
+		if "" != pathname {
+			pathnames = append(pathnames, pathname)
+		}
+	}
+	return pathnames
+}
+
+// tlsReloaded is what Server.ReloadTLS read from the files given to TLS, CA,
+// and ClientCA.  Only these are substituted into the Server's TLSConfig so
+// changes made to it since, for example by TLSProfile, aren't shadowed.
+type tlsReloaded struct {
+	certificates       []tls.Certificate
+	clientCAs, rootCAs *x509.CertPool
+}
+
+// ReloadTLS reads the certificate, private key, and CA files given to TLS,
+// CA, and ClientCA again and uses them for new TLS connections from then on.
+// Established connections are unaffected.  If any file can't be loaded the
+// Server keeps using what it had and an error is returned.  Each reload is
+// logged and counted by the tls-reload and tls-reload-error counters in the
+// given metrics.Registry, which defaults to metrics.DefaultRegistry.
+func (s *Server) ReloadTLS(registry metrics.Registry) error {
+	if nil == registry {
+		registry = metrics.DefaultRegistry
+	}
+	s.mu.Lock()
+	files := s.tlsFiles
+	s.mu.Unlock()
+	if err := s.reloadTLS(files); nil != err {
+		log.Printf("TLS reload failed, keeping old certificates: %s\n", err)
+		metrics.GetOrRegisterCounter("tls-reload-error", registry).Inc(1)
+		return err
+	}
+	log.Printf("TLS reloaded from %v\n", files.pathnames())
+	metrics.GetOrRegisterCounter("tls-reload", registry).Inc(1)
+	return nil
+}
+
+func (s *Server) reloadTLS(files tlsFiles) error {
+	if nil == s.TLSConfig || nil == s.TLSConfig.GetConfigForClient {
+		return errors.New("TLS isn't configured by TLS, CA, or ClientCA")
+	}
+	reloaded := &tlsReloaded{}
+	if "" != files.cert {
+		c, err := tls.LoadX509KeyPair(files.cert, files.key)
+		if nil != err {
+			return err
+		}
+		reloaded.certificates = []tls.Certificate{c}
+	}
+	if "" != files.ca {
+		certPool, err := loadCertPool(files.ca)
+		if nil != err {
+			return err
+		}
+		reloaded.rootCAs = certPool
+	}
+	if "" != files.clientCA {
+		certPool, err := loadCertPool(files.clientCA)
+		if nil != err {
+			return err
+		}
+		reloaded.clientCAs = certPool
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// TLS, CA, or ClientCA may have been given different files while these
+	// were loading, in which case what they loaded is newer.
+	if files != s.tlsFiles {
+		return errors.New("TLS files changed during reload")
+	}
+	s.tlsReloaded.Store(reloaded)
+	return nil
+}
+
+// forgetReloadedTLS is called with s.mu held by TLS, CA, and ClientCA so
+// what they set in the TLSConfig isn't shadowed by an earlier ReloadTLS.
+func (s *Server) forgetReloadedTLS(f func(*tlsReloaded)) {
+	old, _ := s.tlsReloaded.Load().(*tlsReloaded)
+	if nil == old {
+		return
+	}
+	reloaded := *old
+	f(&reloaded)
+	s.tlsReloaded.Store(&reloaded)
+}
+
+// ReloadTLSOn calls ReloadTLS with the given metrics.Registry whenever any
+// of the given signals, for example syscall.SIGHUP, arrives until the Server
+// is closed.
+func (s *Server) ReloadTLSOn(registry metrics.Registry, sig ...os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig...)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ch:
+				s.ReloadTLS(registry)
+			case <-s.ch:
+				return
+			}
+		}
+	}()
+}
+
+// WatchTLS checks the certificate, private key, and CA files given to TLS,
+// CA, and ClientCA for changes at the given interval and calls ReloadTLS
+// with the given metrics.Registry when any of them change until the Server
+// is closed.
+func (s *Server) WatchTLS(interval time.Duration, registry metrics.Registry) {
+	s.mu.Lock()
+	pathnames := s.tlsFiles.pathnames()
+	s.mu.Unlock()
+	modTimes := tlsModTimes(pathnames)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if latest := tlsModTimes(pathnames); latest != modTimes {
+					if nil == s.ReloadTLS(registry) {
+						modTimes = latest
+					}
+				}
+			case <-s.ch:
+				return
+			}
+		}
+	}()
+}
+
+// tlsModTimes summarizes the modification times and sizes of the given
+// files so changes to any of them can be detected.
+func tlsModTimes(pathnames []string) string {
+	var summary string
+	for _, pathname := range pathnames {
+		if fi, err := os.Stat(pathname); nil == err {
+			summary += fmt.Sprintf("%s %d %d\n", pathname, fi.ModTime().UnixNano(), fi.Size())
+		}
+	}
+	return summary
+}
+
+// getConfigForClient is the TLSConfig's GetConfigForClient function, which
+// substitutes the certificates and CAs loaded by ReloadTLS, if any, into a
+// copy of the TLSConfig as it is now.
+func (s *Server) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	reloaded, _ := s.tlsReloaded.Load().(*tlsReloaded)
+	if nil == reloaded {
+		return nil, nil
+	}
+	config := s.TLSConfig.Clone()
+	config.GetConfigForClient = nil
+	if nil != reloaded.certificates {
+		config.Certificates = reloaded.certificates
+	}
+	if nil != reloaded.clientCAs {
+		config.ClientCAs = reloaded.clientCAs
+	}
+	if nil != reloaded.rootCAs {
+		config.RootCAs = reloaded.rootCAs
+	}
+	return config, nil
+}
+
+func loadCertPool(pathname string) (*x509.CertPool, error) {
+	buf, err := ioutil.ReadFile(pathname)
+	if nil != err {
+		return nil, err
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(buf) {
+		return nil, fmt.Errorf("no certificates found in %s", pathname)
+	}
+	return certPool, nil
+}
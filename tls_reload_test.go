@@ -0,0 +1,176 @@
+package tigertonic
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/rcrowley/go-metrics"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServerReloadTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tigertonic")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cert, key := filepath.Join(dir, "test.crt"), filepath.Join(dir, "test.key")
+	writeTestCertificate(t, cert, key, "one")
+	s, err := NewTLSServer("", cert, key, NotFoundHandler{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "one" != testConfigForClientCN(t, s) {
+		t.Fatal(testConfigForClientCN(t, s))
+	}
+	registry := metrics.NewRegistry()
+	writeTestCertificate(t, cert, key, "two")
+	if err := s.ReloadTLS(registry); nil != err {
+		t.Fatal(err)
+	}
+	if "two" != testConfigForClientCN(t, s) {
+		t.Fatal(testConfigForClientCN(t, s))
+	}
+	if err := ioutil.WriteFile(key, []byte("garbage"), 0600); nil != err {
+		t.Fatal(err)
+	}
+	if err := s.ReloadTLS(registry); nil == err {
+		t.Fatal("ReloadTLS should have failed")
+	}
+	if "two" != testConfigForClientCN(t, s) {
+		t.Fatal(testConfigForClientCN(t, s))
+	}
+	if c, ok := registry.Get("tls-reload").(metrics.Counter); !ok || 1 != c.Count() {
+		t.Fatal(registry.Get("tls-reload"))
+	}
+	if c, ok := registry.Get("tls-reload-error").(metrics.Counter); !ok || 1 != c.Count() {
+		t.Fatal(registry.Get("tls-reload-error"))
+	}
+}
+
+func TestServerReloadTLSThenConfigure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tigertonic")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cert, key := filepath.Join(dir, "test.crt"), filepath.Join(dir, "test.key")
+	writeTestCertificate(t, cert, key, "one")
+	s, err := NewTLSServer("", cert, key, NotFoundHandler{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	writeTestCertificate(t, cert, key, "two")
+	if err := s.ReloadTLS(metrics.NewRegistry()); nil != err {
+		t.Fatal(err)
+	}
+
+	// Changes made after the reload must reach new connections.
+	s.TLSProfile(TLSModern)
+	if err := s.ClientCA("test.crt"); nil != err {
+		t.Fatal(err)
+	}
+	config, err := s.TLSConfig.GetConfigForClient(&tls.ClientHelloInfo{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if nil == config {
+		config = s.TLSConfig
+	}
+	if tls.VersionTLS13 != config.MinVersion {
+		t.Fatal(config.MinVersion)
+	}
+	if tls.RequireAndVerifyClientCert != config.ClientAuth || nil == config.ClientCAs {
+		t.Fatal(config.ClientAuth, config.ClientCAs)
+	}
+	if "two" != testConfigForClientCN(t, s) {
+		t.Fatal(testConfigForClientCN(t, s))
+	}
+
+	// A certificate given to TLS after the reload replaces the reloaded one.
+	writeTestCertificate(t, cert, key, "three")
+	if err := s.TLS(cert, key); nil != err {
+		t.Fatal(err)
+	}
+	if "three" != testConfigForClientCN(t, s) {
+		t.Fatal(testConfigForClientCN(t, s))
+	}
+}
+
+func TestServerWatchTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tigertonic")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cert, key := filepath.Join(dir, "test.crt"), filepath.Join(dir, "test.key")
+	writeTestCertificate(t, cert, key, "one")
+	s, err := NewTLSServer("", cert, key, NotFoundHandler{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	s.WatchTLS(time.Millisecond, metrics.NewRegistry())
+	defer s.Close()
+	writeTestCertificate(t, cert, key, "two")
+	for i := 0; i < 1000; i++ {
+		if "two" == testConfigForClientCN(t, s) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal(testConfigForClientCN(t, s))
+}
+
+// testConfigForClientCN returns the common name of the certificate a new
+// TLS connection to the Server would be offered.
+func testConfigForClientCN(t *testing.T, s *Server) string {
+	config, err := s.TLSConfig.GetConfigForClient(&tls.ClientHelloInfo{})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if nil == config {
+		config = s.TLSConfig
+	}
+	c, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if nil != err {
+		t.Fatal(err)
+	}
+	return c.Subject.CommonName
+}
+
+func writeTestCertificate(t *testing.T, cert, key, cn string) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if nil != err {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); nil != err {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); nil != err {
+		t.Fatal(err)
+	}
+}
@@ -1,9 +1,12 @@
 package tigertonic
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"net/url"
+	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -14,14 +17,34 @@ import (
 // Components of the URL pattern surrounded by braces (for example: "{foo}")
 // match any string and create an entry for the string plus the string
 // surrounded by braces in the query parameters (for example: "foo" and
-// "{foo}").
+// "{foo}").  They may be constrained by a regular expression or the name of
+// one of the ParamTypes (for example: "{foo:[0-9]+}" or "{foo:int}"), which
+// must match the whole component.  A final component with an ellipsis (for
+// example: "{foo...}") matches the rest of the URL, slashes and all.
+//
+// Literal components are preferred to wildcards, constrained wildcards to
+// unconstrained ones, and unconstrained wildcards to those that match the
+// rest of the URL.  Registering a URL pattern that's ambiguous with one
+// already registered panics.
 type TrieServeMux struct {
 	methods map[string]http.Handler
-	param   *string
+	params  []*trieParam
 	paths   map[string]*TrieServeMux
 	pattern string
 }
 
+// ParamTypes maps names that may be used to constrain wildcards in URL
+// patterns to the regular expressions they stand for.  Add to it before
+// registering URL patterns that use your own names.
+var ParamTypes = map[string]string{
+	"alpha": `[A-Za-z]+`,
+	"alnum": `[0-9A-Za-z]+`,
+	"float": `[-+]?[0-9]*\.?[0-9]+([eE][-+]?[0-9]+)?`,
+	"int":   `[-+]?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"uuid":  `[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}`,
+}
+
 // NewTrieServeMux makes a new TrieServeMux.
 func NewTrieServeMux() *TrieServeMux {
 	return &TrieServeMux{
@@ -56,6 +79,13 @@ func (mux *TrieServeMux) HandleNamespace(namespace string, handler http.Handler)
 // It sanitizes out any query params that might collide with params parsed
 // from the URL to avoid surprises. See TestCollidingQueryParam for the use case
 func (mux *TrieServeMux) Handler(r *http.Request) (http.Handler, string) {
+	_, handler, pattern := mux.route(r)
+	return handler, pattern
+}
+
+// route is like Handler but also returns the request to pass to the handler,
+// which carries the wildcards in its context.Context.
+func (mux *TrieServeMux) route(r *http.Request) (*http.Request, http.Handler, string) {
 	params, handler, pattern := mux.find(r, strings.Split(r.URL.Path, "/")[1:])
 	if 0 != len(params) {
 		sanitized := r.URL.Query()
@@ -63,15 +93,16 @@ func (mux *TrieServeMux) Handler(r *http.Request) (http.Handler, string) {
 			delete(sanitized, key)
 		}
 		r.URL.RawQuery = params.Encode() + "&" + sanitized.Encode()
+		r = r.WithContext(withPathParams(r.Context(), params))
 	}
-	return handler, pattern
+	return r, handler, pattern
 }
 
 // ServeHTTP routes an HTTP request to the http.Handler registered for the URL
 // pattern which matches the requested path.  It responds 404 if there is no
 // matching URL pattern and 405 if the requested HTTP method is not allowed.
 func (mux *TrieServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	handler, _ := mux.Handler(r)
+	r, handler, _ := mux.route(r)
 	handler.ServeHTTP(w, r)
 }
 
@@ -80,22 +111,54 @@ func (mux *TrieServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // method indicates a namespace.
 func (mux *TrieServeMux) add(method string, paths []string, handler http.Handler, pattern string) {
 	if 0 == len(paths) {
+		if _, ok := mux.methods[method]; ok {
+			if "" == method {
+				panic(fmt.Sprintf("tigertonic: namespace %s conflicts with namespace %s", pattern, mux.pattern))
+			}
+			panic(fmt.Sprintf("tigertonic: %s %s conflicts with %s %s", method, pattern, method, mux.pattern))
+		}
 		mux.methods[method] = handler
 		mux.pattern = pattern
 		return
 	}
-	if strings.HasPrefix(paths[0], "{") && strings.HasSuffix(paths[0], "}") {
-		mux.param = &paths[0]
-	}
 	if _, ok := mux.paths[paths[0]]; !ok {
+		param, err := parseTrieParam(paths[0])
+		if nil != err {
+			panic(fmt.Sprintf("tigertonic: %s: %s", pattern, err))
+		}
+		if nil != param {
+			if param.catchAll && 1 != len(paths) {
+				panic(fmt.Sprintf("tigertonic: %s: %s must be the last component", pattern, param.segment))
+			}
+			mux.addParam(param, pattern)
+		}
 		mux.paths[paths[0]] = NewTrieServeMux()
 	}
 	mux.paths[paths[0]].add(method, paths[1:], handler, pattern)
 }
 
+// addParam adds a wildcard to this node of the trie, keeping wildcards
+// in the order they should be tried, and panics if it's ambiguous with a
+// wildcard already there.
+func (mux *TrieServeMux) addParam(param *trieParam, pattern string) {
+	i := len(mux.params)
+	for j, other := range mux.params {
+		if param.rank() == other.rank() && param.constraint == other.constraint {
+			panic(fmt.Sprintf("tigertonic: %s: %s conflicts with %s", pattern, param.segment, other.segment))
+		}
+		if param.rank() < other.rank() && i == len(mux.params) {
+			i = j
+		}
+	}
+	mux.params = append(mux.params, nil)
+	copy(mux.params[i+1:], mux.params[i:])
+	mux.params[i] = param
+}
+
 // find recursively searches for a URL pattern in the trie, strips
 // namespace components from the URL, adds wildcards to the query parameters,
 // and returns extra query parameters, a handler, and the pattern that matched.
+// A literal component that leads nowhere falls back to matching wildcards.
 func (mux *TrieServeMux) find(r *http.Request, paths []string) (url.Values, http.Handler, string) {
 	if 0 == len(paths) {
 		if handler, ok := mux.methods[r.Method]; ok {
@@ -104,15 +167,34 @@ func (mux *TrieServeMux) find(r *http.Request, paths []string) (url.Values, http
 		return nil, MethodNotAllowedHandler{mux}, ""
 	}
 	if _, ok := mux.paths[paths[0]]; ok {
-		return mux.paths[paths[0]].find(r, paths[1:])
+		params, handler, pattern := mux.paths[paths[0]].find(r, paths[1:])
+		if _, ok := handler.(NotFoundHandler); !ok {
+			return params, handler, pattern
+		}
 	}
-	if nil != mux.param {
-		params, handler, pattern := mux.paths[*mux.param].find(r, paths[1:])
+	for _, param := range mux.params {
+		var (
+			params  url.Values
+			handler http.Handler
+			pattern string
+			value   = paths[0]
+		)
+		if param.catchAll {
+			value = strings.Join(paths, "/")
+			params, handler, pattern = mux.paths[param.segment].find(r, nil)
+		} else if nil == param.re || param.re.MatchString(value) {
+			params, handler, pattern = mux.paths[param.segment].find(r, paths[1:])
+		} else {
+			continue
+		}
+		if _, ok := handler.(NotFoundHandler); ok {
+			continue
+		}
 		if nil == params {
 			params = make(url.Values)
 		}
-		params.Set(*mux.param, paths[0])
-		params.Set(strings.Trim(*mux.param, "{}"), paths[0])
+		params.Set("{"+param.name+"}", value)
+		params.Set(param.name, value)
 		return params, handler, pattern
 	}
 	if handler, ok := mux.methods[""]; ok {
@@ -121,3 +203,132 @@ func (mux *TrieServeMux) find(r *http.Request, paths []string) (url.Values, http
 	}
 	return nil, NotFoundHandler{}, ""
 }
+
+// trieParam is a wildcard component of a URL pattern.
+type trieParam struct {
+	segment    string // as it appears in the URL pattern
+	name       string
+	constraint string
+	re         *regexp.Regexp
+	catchAll   bool
+}
+
+// parseTrieParam parses a URL pattern component, returning nil if it's not
+// a wildcard.
+func parseTrieParam(segment string) (*trieParam, error) {
+	if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+		return nil, nil
+	}
+	param := &trieParam{segment: segment, name: segment[1 : len(segment)-1]}
+	if strings.HasSuffix(param.name, "...") {
+		param.name, param.catchAll = strings.TrimSuffix(param.name, "..."), true
+	} else if i := strings.Index(param.name, ":"); -1 != i {
+		param.name, param.constraint = param.name[:i], param.name[i+1:]
+		expr, ok := ParamTypes[param.constraint]
+		if !ok {
+			expr = param.constraint
+		}
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if nil != err {
+			return nil, err
+		}
+		param.re = re
+	}
+	if "" == param.name {
+		return nil, fmt.Errorf("%s has no name", segment)
+	}
+	return param, nil
+}
+
+// rank orders wildcards by how eagerly they should be tried.
+func (param *trieParam) rank() int {
+	if param.catchAll {
+		return 2
+	}
+	if nil == param.re {
+		return 1
+	}
+	return 0
+}
+
+// Route describes an http.Handler registered in a TrieServeMux or
+// HostServeMux.  Pattern is the whole URL pattern, including the namespaces
+// leading to it, and Namespace is just those namespaces.  Method is empty
+// for namespaces whose http.Handler isn't itself a multiplexer.  Roles and
+// Scopes are those an AccessPolicy requires of the route, if any.
+type Route struct {
+	Host      string
+	Method    string
+	Pattern   string
+	Namespace string
+	Handler   http.Handler
+	Roles     []string
+	Scopes    []string
+}
+
+func (route Route) String() string {
+	if "" == route.Method {
+		return route.Host + route.Pattern + " (namespace)"
+	}
+	return route.Method + " " + route.Host + route.Pattern
+}
+
+// Routes returns every http.Handler registered in the trie, in lexical
+// order, recursing into TrieServeMuxes and HostServeMuxes registered as
+// namespaces even if they're wrapped in other Tiger Tonic middleware.
+func (mux *TrieServeMux) Routes() []Route {
+	return mux.routes(nil, "", "", nil)
+}
+
+func (mux *TrieServeMux) routes(paths []string, namespace, host string, routes []Route) []Route {
+	methods := make([]string, 0, len(mux.methods))
+	for method := range mux.methods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		pattern := namespace + "/" + strings.Join(paths, "/")
+		if "" != method {
+			roles, scopes := accessRequirements(mux.methods[method])
+			routes = append(routes, Route{host, method, pattern, namespace, mux.methods[method], roles, scopes})
+			continue
+		}
+		if 0 == len(paths) {
+			pattern = namespace
+		}
+		if nested, ok := nestedRoutes(mux.methods[method], pattern, host); ok {
+			routes = append(routes, nested...)
+		} else {
+			routes = append(routes, Route{host, "", pattern, namespace, mux.methods[method], nil, nil})
+		}
+	}
+	keys := make([]string, 0, len(mux.paths))
+	for key := range mux.paths {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		routes = mux.paths[key].routes(append(paths[:len(paths):len(paths)], key), namespace, host, routes)
+	}
+	return routes
+}
+
+// nestedRoutes returns the routes of the TrieServeMux or HostServeMux
+// wrapped by the given http.Handler, if there is one.
+func nestedRoutes(handler http.Handler, namespace, host string) ([]Route, bool) {
+	switch mux := findHandler(handler, isServeMux).(type) {
+	case *TrieServeMux:
+		return mux.routes(nil, namespace, host, nil), true
+	case HostServeMux:
+		return mux.routes(namespace), true
+	}
+	return nil, false
+}
+
+func isServeMux(handler http.Handler) bool {
+	switch handler.(type) {
+	case *TrieServeMux, HostServeMux:
+		return true
+	}
+	return false
+}
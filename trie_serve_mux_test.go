@@ -193,3 +193,117 @@ func TestCollidingQueryParam(t *testing.T) {
 		t.Fatal("Param passed in via query parameter overwrote URL param")
 	}
 }
+
+func TestRoutes(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.HandleFunc("POST", "/foo/{bar}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET", "/foo/{bar}", func(w http.ResponseWriter, r *http.Request) {})
+	nsMux := NewTrieServeMux()
+	nsMux.HandleFunc("GET", "/", func(w http.ResponseWriter, r *http.Request) {})
+	nsMux.HandleNamespace("/1.0", Logged(mux, nil))
+	nsMux.HandleNamespace("/static", http.NotFoundHandler())
+	hMux := NewHostServeMux()
+	hMux.Handle("example.com", nsMux)
+	routes := hMux.Routes()
+	expected := []string{
+		"GET example.com/",
+		"GET example.com/1.0/foo/{bar}",
+		"POST example.com/1.0/foo/{bar}",
+		"example.com/static (namespace)",
+	}
+	if len(expected) != len(routes) {
+		t.Fatal(routes)
+	}
+	for i, route := range routes {
+		if expected[i] != route.String() {
+			t.Fatal(i, route)
+		}
+	}
+	if "/1.0" != routes[1].Namespace || "" != routes[0].Namespace {
+		t.Fatal(routes)
+	}
+	if 4 != len(nsMux.Routes()) || "" != nsMux.Routes()[0].Host {
+		t.Fatal(nsMux.Routes())
+	}
+}
+
+func TestConstrainedParams(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.HandleFunc("GET", "/foo/{id:int}", func(w http.ResponseWriter, r *http.Request) {
+		if "47" != r.URL.Query().Get("id") || "47" != r.URL.Query().Get("{id}") {
+			t.Fatal(r.URL.Query())
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET", "/foo/{slug:[a-z]+}", func(w http.ResponseWriter, r *http.Request) {
+		if "bar" != r.URL.Query().Get("slug") {
+			t.Fatal(r.URL.Query())
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("GET", "/foo/{other}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusResetContent)
+	})
+	for path, status := range map[string]int{
+		"/foo/47":   http.StatusNoContent,
+		"/foo/bar":  http.StatusAccepted,
+		"/foo/Bar":  http.StatusResetContent,
+		"/foo/47/x": http.StatusNotFound,
+	} {
+		w := &testResponseWriter{}
+		r, _ := http.NewRequest("GET", "http://example.com"+path, nil)
+		mux.ServeHTTP(w, r)
+		if status != w.StatusCode {
+			t.Fatal(path, w.StatusCode)
+		}
+	}
+}
+
+func TestCatchAllParam(t *testing.T) {
+	mux := NewTrieServeMux()
+	mux.HandleFunc("GET", "/files/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		if "a/b/c.txt" != r.URL.Query().Get("path") {
+			t.Fatal(r.URL.Query())
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET", "/files/{dir}/index", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("GET", "http://example.com/files/a/b/c.txt", nil)
+	mux.ServeHTTP(w, r)
+	if http.StatusNoContent != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	w = &testResponseWriter{}
+	r, _ = http.NewRequest("GET", "http://example.com/files/a/index", nil)
+	mux.ServeHTTP(w, r)
+	if http.StatusAccepted != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+}
+
+func TestConflictingPatterns(t *testing.T) {
+	for _, patterns := range [][2]string{
+		{"/foo/{id}", "/foo/{other}"},
+		{"/foo/{id:int}", "/foo/{other:int}"},
+		{"/foo/{path...}", "/foo/{rest...}"},
+		{"/foo/{id}", "/foo/{id}"},
+		{"/foo/{path...}/bar", ""},
+		{"/foo/{id:[}", ""},
+	} {
+		func() {
+			defer func() {
+				if nil == recover() {
+					t.Fatal(patterns)
+				}
+			}()
+			mux := NewTrieServeMux()
+			mux.HandleFunc("GET", patterns[0], func(w http.ResponseWriter, r *http.Request) {})
+			if "" != patterns[1] {
+				mux.HandleFunc("GET", patterns[1], func(w http.ResponseWriter, r *http.Request) {})
+			}
+		}()
+	}
+}
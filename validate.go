@@ -0,0 +1,333 @@
+package tigertonic
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Validator is implemented by request types that know how to check
+// themselves.  Marshaler calls Validate after unmarshaling the request body
+// and before calling the handler function.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError names one request field, by its JSON path, that failed
+// validation and says why.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is an HTTPEquivError that collects every FieldError found
+// in a request so they can all be reported in one 422 response.
+type ValidationError []FieldError
+
+func (err ValidationError) Error() string {
+	messages := make([]string, len(err))
+	for i, fe := range err {
+		if "" == fe.Field {
+			messages[i] = fe.Message
+		} else {
+			messages[i] = fmt.Sprintf("%s: %s", fe.Field, fe.Message)
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (err ValidationError) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+// Validate checks the value against the rules declared in its validate
+// struct tags, recursing into nested structs, slices, and maps, and then
+// calls its Validate method if it has one.  Struct tags look like
+//
+//	Name  string `json:"name" validate:"required,max=64"`
+//	Kind  string `json:"kind" validate:"enum=foo|bar|baz"`
+//	Slug  string `json:"slug" validate:"len=8,regexp=^[a-z0-9]+$"`
+//
+// where min and max bound numbers by value and strings, slices, and maps by
+// length.  Rules besides required don't apply to zero values, so a field
+// that's optional but constrained when given may be omitted; use a pointer
+// to constrain a given zero value.  Since regular expressions may contain
+// commas, regexp must be the last rule in the tag.  The Validate method is
+// only called once every tag is satisfied.  The returned error is a
+// ValidationError naming every field that failed, or an HTTPEquivError from
+// a Validate method.
+func Validate(i interface{}) error {
+	var errs ValidationError
+	validateValue(reflect.ValueOf(i), "", &errs)
+	if 0 != len(errs) {
+		return errs
+	}
+	if v, ok := i.(Validator); ok {
+		if err := v.Validate(); nil != err {
+			if _, ok := err.(HTTPEquivError); ok {
+				return err
+			}
+			return UnprocessableEntity{err}
+		}
+	}
+	return nil
+}
+
+type validateRule struct {
+	required bool
+	min, max *float64
+	length   *int
+	enum     []string
+	re       *regexp.Regexp
+}
+
+var validateRules sync.Map // map[string]*validateRule, keyed by tag
+
+func parseValidateTag(tag string) (*validateRule, error) {
+	if rule, ok := validateRules.Load(tag); ok {
+		return rule.(*validateRule), nil
+	}
+	rule := &validateRule{}
+	for rest := tag; "" != rest; {
+		var s string
+		if strings.HasPrefix(rest, "regexp=") {
+			s, rest = rest, ""
+		} else if i := strings.Index(rest, ","); -1 == i {
+			s, rest = rest, ""
+		} else {
+			s, rest = rest[:i], rest[i+1:]
+		}
+		name, value := s, ""
+		if i := strings.Index(s, "="); -1 != i {
+			name, value = s[:i], s[i+1:]
+		}
+		switch name {
+		case "required":
+			rule.required = true
+		case "min", "max":
+			f, err := strconv.ParseFloat(value, 64)
+			if nil != err {
+				return nil, fmt.Errorf("validate tag %q: %s", tag, err)
+			}
+			if "min" == name {
+				rule.min = &f
+			} else {
+				rule.max = &f
+			}
+		case "len":
+			n, err := strconv.Atoi(value)
+			if nil != err {
+				return nil, fmt.Errorf("validate tag %q: %s", tag, err)
+			}
+			rule.length = &n
+		case "enum":
+			rule.enum = strings.Split(value, "|")
+		case "regexp":
+			re, err := regexp.Compile(value)
+			if nil != err {
+				return nil, fmt.Errorf("validate tag %q: %s", tag, err)
+			}
+			rule.re = re
+		default:
+			return nil, fmt.Errorf("validate tag %q: unknown rule %q", tag, name)
+		}
+	}
+	validateRules.Store(tag, rule)
+	return rule, nil
+}
+
+// checkValidateTags parses every validate tag reachable from the given type
+// so misconfigured request types are found when Marshaled is called rather
+// than when the first request arrives.
+func checkValidateTags(t reflect.Type, seen map[reflect.Type]bool) error {
+	for reflect.Ptr == t.Kind() || reflect.Slice == t.Kind() || reflect.Array == t.Kind() || reflect.Map == t.Kind() {
+		t = t.Elem()
+	}
+	if reflect.Struct != t.Kind() || seen[t] {
+		return nil
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if tag := f.Tag.Get("validate"); "" != tag {
+			if _, err := parseValidateTag(tag); nil != err {
+				return fmt.Errorf("%s.%s: %s", t, f.Name, err)
+			}
+		}
+		if err := checkValidateTags(f.Type, seen); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateValue(v reflect.Value, path string, errs *ValidationError) {
+	for reflect.Ptr == v.Kind() || reflect.Interface == v.Kind() {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	n := len(*errs)
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if "" != f.PkgPath {
+				continue
+			}
+			name := jsonFieldName(f)
+			if source := paramSource(f); "" != source && "" == f.Tag.Get("json") {
+				name = f.Tag.Get(source)
+			}
+			if "-" == name {
+				continue
+			}
+			fv := v.Field(i)
+			if f.Anonymous && "" == f.Tag.Get("json") {
+				validateValue(fv, path, errs)
+				continue
+			}
+			fpath := name
+			if "" != path {
+				fpath = path + "." + name
+			}
+			if tag := f.Tag.Get("validate"); "" != tag {
+				rule, err := parseValidateTag(tag)
+				if nil != err {
+					panic(NewMarshalerError("%s", err))
+				}
+				if !validateField(fv, fpath, rule, errs) {
+					continue
+				}
+			}
+			validateValue(fv, fpath, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			validateValue(v.MapIndex(key), fmt.Sprintf("%s[%v]", path, key), errs)
+		}
+	}
+	if "" == path || len(*errs) != n {
+		return
+	}
+	if v.CanAddr() {
+		v = v.Addr()
+	}
+	if validator, ok := v.Interface().(Validator); ok {
+		if err := validator.Validate(); nil != err {
+			if ve, ok := err.(ValidationError); ok {
+				for _, fe := range ve {
+					if "" == fe.Field {
+						fe.Field = path
+					} else {
+						fe.Field = path + "." + fe.Field
+					}
+					*errs = append(*errs, fe)
+				}
+			} else {
+				*errs = append(*errs, FieldError{path, err.Error()})
+			}
+		}
+	}
+}
+
+// validateField applies a parsed validate tag to a single field and reports
+// whether there's anything left inside the field worth validating.
+func validateField(v reflect.Value, path string, rule *validateRule, errs *ValidationError) bool {
+	fail := func(format string, args ...interface{}) bool {
+		*errs = append(*errs, FieldError{path, fmt.Sprintf(format, args...)})
+		return false
+	}
+	zero := v.IsZero()
+	for reflect.Ptr == v.Kind() || reflect.Interface == v.Kind() {
+		if v.IsNil() {
+			if rule.required {
+				return fail("is required")
+			}
+			return false
+		}
+		v = v.Elem()
+	}
+	if rule.required && v.IsZero() {
+		return fail("is required")
+	}
+
+	// Other rules only apply to fields that were given.  A pointer tells a
+	// given zero value apart from an omitted field.
+	if zero {
+		return true
+	}
+	var size float64
+	sized := true
+	switch v.Kind() {
+	case reflect.String:
+		size = float64(utf8.RuneCountInString(v.String()))
+	case reflect.Slice, reflect.Array, reflect.Map:
+		size = float64(v.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size, sized = float64(v.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size, sized = float64(v.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		size, sized = v.Float(), false
+	default:
+		sized = false
+	}
+	if nil != rule.length && (!sized || size != float64(*rule.length)) {
+		return fail("must have length %d", *rule.length)
+	}
+	if nil != rule.min && size < *rule.min {
+		if sized {
+			return fail("must have length at least %v", *rule.min)
+		}
+		return fail("must be at least %v", *rule.min)
+	}
+	if nil != rule.max && size > *rule.max {
+		if sized {
+			return fail("must have length at most %v", *rule.max)
+		}
+		return fail("must be at most %v", *rule.max)
+	}
+	if nil != rule.enum {
+		s := fmt.Sprint(v.Interface())
+		found := false
+		for _, e := range rule.enum {
+			if e == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fail("must be one of %s", strings.Join(rule.enum, ", "))
+		}
+	}
+	if nil != rule.re {
+		if reflect.String != v.Kind() || !rule.re.MatchString(v.String()) {
+			return fail("must match %s", rule.re)
+		}
+	}
+	return true
+}
+
+// jsonFieldName returns the name encoding/json would use for the field.
+func jsonFieldName(f reflect.StructField) string {
+	tag := f.Tag.Get("json")
+	if i := strings.Index(tag, ","); -1 != i {
+		tag = tag[:i]
+	}
+	if "" == tag {
+		return f.Name
+	}
+	return tag
+}
@@ -0,0 +1,137 @@
+package tigertonic
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestValidateTags(t *testing.T) {
+	err := Validate(&testValidateRequest{
+		Age:   12,
+		Kind:  "quux",
+		Slug:  "NOPE",
+		Items: []testValidateItem{{"ok"}, {""}},
+	})
+	errs, ok := err.(ValidationError)
+	if !ok {
+		t.Fatal(err)
+	}
+	expected := []FieldError{
+		{"name", "is required"},
+		{"age", "must be at least 18"},
+		{"kind", "must be one of foo, bar"},
+		{"slug", "must match ^[a-z]+$"},
+		{"items[1].id", "is required"},
+	}
+	if len(expected) != len(errs) {
+		t.Fatal(errs)
+	}
+	for i, fe := range expected {
+		if fe != errs[i] {
+			t.Error(i, errs[i])
+		}
+	}
+}
+
+func TestValidateLength(t *testing.T) {
+	err := Validate(&testValidateRequest{
+		Name: "abcdefghi",
+		Age:  18,
+		Kind: "foo",
+		Slug: "abc",
+	})
+	if "name: must have length at most 8" != err.Error() {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateMethod(t *testing.T) {
+	err := Validate(&testValidateRequest{
+		Name: "rcrowley",
+		Age:  18,
+		Kind: "bar",
+		Slug: "abc",
+	})
+	if _, ok := err.(UnprocessableEntity); !ok {
+		t.Fatal(err)
+	}
+	if "bar is not allowed for rcrowley" != err.Error() {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateOK(t *testing.T) {
+	if err := Validate(&testValidateRequest{
+		Name: "rcrowley",
+		Age:  18,
+		Kind: "foo",
+		Slug: "abc",
+	}); nil != err {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateOmitted(t *testing.T) {
+	if err := Validate(&testValidateRequest{Name: "rcrowley"}); nil != err {
+		t.Fatal(err)
+	}
+	type request struct {
+		Age  *int   `json:"age" validate:"min=18"`
+		Kind string `json:"kind" validate:"enum=foo|bar"`
+	}
+	if err := Validate(&request{}); nil != err {
+		t.Fatal(err)
+	}
+	age := 0
+	err := Validate(&request{Age: &age, Kind: "quux"})
+	if "age: must be at least 18; kind: must be one of foo, bar" != err.Error() {
+		t.Fatal(err)
+	}
+}
+
+func TestMarshaledPanicValidateTag(t *testing.T) {
+	testMarshaledPanic(func(u *url.URL, h http.Header, rq *struct {
+		Foo string `validate:"min=foo"`
+	}) (int, http.Header, *testResponse, error) {
+		return 0, nil, nil, nil
+	}, t)
+}
+
+func TestMarshaledUnprocessableEntity(t *testing.T) {
+	w := &testResponseWriter{}
+	r, _ := http.NewRequest("POST", "http://example.com/foo", bytes.NewBufferString(`{"age":12,"kind":"foo","slug":"abc"}`))
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Content-Type", "application/json")
+	Marshaled(func(u *url.URL, h http.Header, rq *testValidateRequest) (int, http.Header, *testResponse, error) {
+		t.Fatal("handler called with invalid request")
+		return http.StatusNoContent, nil, nil, nil
+	}).ServeHTTP(w, r)
+	if http.StatusUnprocessableEntity != w.StatusCode {
+		t.Fatal(w.StatusCode)
+	}
+	if "{\"description\":\"name: is required; age: must be at least 18\",\"error\":\"tigertonic.ValidationError\",\"fields\":[{\"field\":\"name\",\"message\":\"is required\"},{\"field\":\"age\",\"message\":\"must be at least 18\"}]}\n" != w.Body.String() {
+		t.Fatal(w.Body.String())
+	}
+}
+
+type testValidateRequest struct {
+	Name  string             `json:"name" validate:"required,max=8"`
+	Age   int                `json:"age" validate:"min=18,max=150"`
+	Kind  string             `json:"kind,omitempty" validate:"enum=foo|bar"`
+	Slug  string             `json:"slug" validate:"regexp=^[a-z]+$"`
+	Items []testValidateItem `json:"items"`
+}
+
+func (rq *testValidateRequest) Validate() error {
+	if "bar" == rq.Kind {
+		return errors.New("bar is not allowed for " + rq.Name)
+	}
+	return nil
+}
+
+type testValidateItem struct {
+	ID string `json:"id" validate:"required"`
+}